@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync/atomic"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/utc-go"
+)
+
+var (
+	// processStart is the start time of the process reported in the banner.
+	processStart = utc.Now()
+	// pVersionInfo is a pointer to the build metadata - nil until set
+	pVersionInfo atomic.Pointer[versionInfo]
+)
+
+type versionInfo struct {
+	version string
+	commit  string
+}
+
+// SetVersionInfo sets the build metadata of the process: its version and the
+// commit it was built from. They are reported in the banner of log files (see
+// Config.Banner) and as 'version' and 'commit' fields of all entries if
+// Config.IncludeVersion is enabled. Empty values are omitted. Setting both to
+// empty values resets the build metadata.
+func SetVersionInfo(version, commit string) {
+	if version == "" && commit == "" {
+		pVersionInfo.Store(nil)
+		return
+	}
+	pVersionInfo.Store(&versionInfo{
+		version: version,
+		commit:  commit,
+	})
+}
+
+// appendVersionInfo appends the version and commit fields to the given fields
+// if set.
+func appendVersionInfo(fields []interface{}) []interface{} {
+	vi := pVersionInfo.Load()
+	if vi == nil {
+		return fields
+	}
+	if vi.version != "" {
+		fields = append(fields, "version", vi.version)
+	}
+	if vi.commit != "" {
+		fields = append(fields, "commit", vi.commit)
+	}
+	return fields
+}
+
+// bannerFunc returns the function rendering the banner of log files with the
+// given config, or nil if the config disables the banner, see Config.Banner.
+// The banner is rendered by the configured handler and compressed as separate
+// gzip member if configured with CompressStream.
+func bannerFunc(c *Config) func() []byte {
+	if c.Banner == nil || !*c.Banner || c.Handler == "audit" {
+		return nil
+	}
+	compress := c.CompressStream != nil && *c.CompressStream
+	return func() []byte {
+		buf := &bytes.Buffer{}
+		if !compress {
+			writeBanner(newHandler(c, buf))
+			return buf.Bytes()
+		}
+		gz := gzip.NewWriter(buf)
+		writeBanner(newHandler(c, gz))
+		_ = gz.Close()
+		return buf.Bytes()
+	}
+}
+
+// writeBanner writes a "log started" entry identifying the process with the
+// given handler: the process name, pid, version and commit (see SetVersionInfo;
+// default: the version of the main module) and start time of the process.
+func writeBanner(handler apex.Handler) {
+	lg := &apex.Logger{
+		Handler: handler,
+		Level:   apex.InfoLevel,
+	}
+	fields := []interface{}{
+		"process", filepath.Base(os.Args[0]),
+		"pid", os.Getpid(),
+	}
+	if pVersionInfo.Load() != nil {
+		fields = appendVersionInfo(fields)
+	} else {
+		fields = append(fields, "version", mainVersion())
+	}
+	fields = append(fields, "start", processStart.String())
+	lg.Info("log started", fields...)
+}
+
+// mainVersion returns the version of the main module or an empty string if
+// unknown.
+func mainVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return bi.Main.Version
+}
@@ -110,6 +110,17 @@ func TestSimpleLog(b *testing.T) {
 // -- with atomic wrapper (2)
 //BenchmarkLog/file-config-8         	   72332	     16735 ns/op	    3793 B/op	      51 allocs/op
 //BenchmarkLog/file-config-10-fields-8     135945	      8894 ns/op	    1153 B/op	      24 allocs/op
+// -- pooled fields buffer for go routine ID / caller
+// goos: linux - goarch: amd64
+// before:
+//BenchmarkLog/file-config-gid         	   50000	     16456 ns/op	    2536 B/op	      60 allocs/op
+//BenchmarkLog/file-config-gid         	   50000	     15814 ns/op	    2536 B/op	      60 allocs/op
+// after:
+//BenchmarkLog/file-config-gid         	   50000	     15479 ns/op	    2120 B/op	      59 allocs/op
+//BenchmarkLog/file-config-gid         	   50000	     20699 ns/op	    2120 B/op	      59 allocs/op
+// unchanged without go routine ID / caller (fields are not appended):
+//BenchmarkLog/file-config             	   20000	      9487 ns/op	    1896 B/op	      53 allocs/op
+//BenchmarkLog/file-config-10-fields   	   20000	      4951 ns/op	     584 B/op	      26 allocs/op
 
 func BenchmarkLog(b *testing.B) {
 	path, err := os.MkdirTemp(os.TempDir(), "benchmarkLog")
@@ -151,6 +162,19 @@ func BenchmarkLog(b *testing.B) {
 		}
 	})
 
+	// test with go routine ID - fields are appended to a pooled buffer
+	trueVal := true
+	gidCfg := *cfg
+	gidCfg.GoRoutineID = &trueVal
+	gidLog := newLog(&gidCfg, defaultFields(&gidCfg, "/"), nil)
+	b.Run("file-config-gid", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e := entries[i%len(entries)]
+			gidLog.Info(e.Message, e.Fields...)
+		}
+	})
+
 	// test with pre-allocated fields
 	defaultFields := func(c *Config, path string) *apex.Fields {
 		f := apex.Fields(nil).
@@ -252,3 +276,49 @@ func BenchmarkNoLog(b *testing.B) {
 	})
 
 }
+
+// BenchmarkCrashBuffer measures the cost of the crash buffer, which renders
+// each entry a second time with the text handler:
+// - with a json handler writing to a file, the common production setup
+// - with a discard handler, which isolates the cost of the crash buffer
+//
+// goos: linux - goarch: amd64
+// BenchmarkCrashBuffer/json-without-crash-buffer      200000      7275 ns/op    2440 B/op    61 allocs/op
+// BenchmarkCrashBuffer/json-with-crash-buffer         200000      8735 ns/op    2801 B/op    76 allocs/op
+// BenchmarkCrashBuffer/discard-without-crash-buffer   200000      1105 ns/op     848 B/op    15 allocs/op
+// BenchmarkCrashBuffer/discard-with-crash-buffer      200000      2625 ns/op    1208 B/op    30 allocs/op
+//
+// i.e. about 1.5µs and 15 allocations per emitted entry, some 20% of writing
+// the entry as json to a file. Entries of disabled levels are not affected.
+func BenchmarkCrashBuffer(b *testing.B) {
+	defer setCrashBuffer(0)
+
+	path := b.TempDir()
+	for _, handler := range []string{"json", "discard"} {
+		cfg := &Config{
+			Level:   "info",
+			Handler: handler,
+			File: &LumberjackConfig{
+				Filename: filepath.Join(path, handler+".log"),
+			}}
+		log := newLog(cfg, defaultFields(cfg, "/"), nil)
+		for _, size := range []int{-1, 0} {
+			name := handler + "-with-crash-buffer"
+			if size < 0 {
+				name = handler + "-without-crash-buffer"
+			}
+			setCrashBuffer(size)
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					log.Info("request handled",
+						"method", "GET",
+						"path", "/api/v1/items",
+						"status", 200,
+						"elapsed", 1234)
+				}
+			})
+		}
+		_ = log.Close()
+	}
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+	"time"
+)
+
+// gzipFlushInterval is the interval at which compressed output is flushed to
+// the underlying writer.
+const gzipFlushInterval = time.Second
+
+// gzipWriter compresses the output of a log with gzip. The compressed data is
+// flushed periodically, on Sync and on Close. Writes after Close start a new
+// gzip member, which gzip readers decompress as concatenated stream.
+type gzipWriter struct {
+	mutex        sync.Mutex
+	writer       io.Writer    // the underlying writer
+	closer       io.Closer    // closes the underlying writer - nil if not owned (e.g. stdout)
+	gz           *gzip.Writer // nil until the first write after creation or close
+	flushPending bool         // true if a flush is scheduled
+}
+
+// streamGzips holds the gzip writers shared by all logs compressing the same
+// output stream, keyed by the stream's writer, see compressedStream.
+var streamGzips sync.Map
+
+// compressedStream returns the gzip writer shared by all logs with
+// CompressStream writing to the given output stream (stdout or stderr), so
+// that their output forms a single gzip stream.
+func compressedStream(w io.Writer) *gzipWriter {
+	gzw, _ := streamGzips.LoadOrStore(w, newGzipWriter(w, nil))
+	return gzw.(*gzipWriter)
+}
+
+// closeCompressedStreams completes the gzip streams of all compressed output
+// streams. Subsequent writes start new gzip members.
+func closeCompressedStreams() {
+	streamGzips.Range(func(_, gzw interface{}) bool {
+		_ = gzw.(*gzipWriter).Close()
+		return true
+	})
+}
+
+// syncCompressedOutputs flushes the pending compressed data of all logs, i.e.
+// of all compressed log files and output streams.
+func syncCompressedOutputs() {
+	sharedFiles.Range(func(_, f interface{}) bool {
+		_ = f.(*sharedFile).compressed().Sync()
+		return true
+	})
+	streamGzips.Range(func(_, gzw interface{}) bool {
+		_ = gzw.(*gzipWriter).Sync()
+		return true
+	})
+}
+
+func newGzipWriter(w io.Writer, closer io.Closer) *gzipWriter {
+	return &gzipWriter{
+		writer: w,
+		closer: closer,
+	}
+}
+
+// Write compresses the given data.
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.gz == nil {
+		g.gz = gzip.NewWriter(g.writer)
+	}
+	if !g.flushPending {
+		g.flushPending = true
+		time.AfterFunc(gzipFlushInterval, func() {
+			_ = g.flush()
+		})
+	}
+	return g.gz.Write(p)
+}
+
+// flush writes the pending compressed data to the underlying writer.
+func (g *gzipWriter) flush() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.flushPending = false
+	if g.gz == nil {
+		return nil
+	}
+	return g.gz.Flush()
+}
+
+// Sync flushes the pending compressed data to the underlying writer.
+func (g *gzipWriter) Sync() error {
+	return g.flush()
+}
+
+// Close completes the gzip stream and closes the underlying writer if owned.
+func (g *gzipWriter) Close() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	var err error
+	if g.gz != nil {
+		err = g.gz.Close()
+		g.gz = nil
+	}
+	if g.closer != nil {
+		if cerr := g.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
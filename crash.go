@@ -0,0 +1,130 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/text"
+)
+
+// pCrashBuffer is a pointer to the process-wide crash buffer - nil if disabled
+var pCrashBuffer atomic.Pointer[crashBuffer]
+
+// defaultCrashBufferSize is the size of the crash buffer if not configured.
+const defaultCrashBufferSize = 100
+
+// unresolvedLazy is the crash buffer rendering of lazy values that were not
+// resolved by the handler of the entry.
+const unresolvedLazy = "<lazy>"
+
+// crashBuffer is a ring buffer of the most recent log lines, rendered with the
+// text handler.
+type crashBuffer struct {
+	mu      sync.Mutex
+	lines   []string      // the ring buffer
+	start   int           // the index of the oldest line in the ring buffer
+	count   int           // the number of lines in the ring buffer
+	handler *text.Handler // renders entries into the buffer
+}
+
+func newCrashBuffer(size int) *crashBuffer {
+	ret := &crashBuffer{lines: make([]string, size)}
+	ret.handler = text.New(ret)
+	return ret
+}
+
+// Write implements io.Writer and adds the given rendered entry as line.
+func (b *crashBuffer) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx := (b.start + b.count) % len(b.lines)
+	if b.count == len(b.lines) {
+		// discard the oldest line
+		b.start = (b.start + 1) % len(b.lines)
+	} else {
+		b.count++
+	}
+	b.lines[idx] = line
+	return len(p), nil
+}
+
+// dump returns a copy of the lines, oldest first.
+func (b *crashBuffer) dump() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ret := make([]string, b.count)
+	for i := range ret {
+		ret[i] = b.lines[(b.start+i)%len(b.lines)]
+	}
+	return ret
+}
+
+// setCrashBuffer enables the crash buffer with the given size, the default
+// size if 0, or disables it if size < 0. Changing the size discards the
+// captured lines.
+func setCrashBuffer(size int) {
+	if size < 0 {
+		pCrashBuffer.Store(nil)
+		return
+	}
+	if size == 0 {
+		size = defaultCrashBufferSize
+	}
+	if b := pCrashBuffer.Load(); b != nil && len(b.lines) == size {
+		return
+	}
+	pCrashBuffer.Store(newCrashBuffer(size))
+}
+
+// captureCrashLine renders the given entry into the crash buffer if enabled.
+// It is called after the entry was handled: lazy values that were not resolved
+// by the handler, e.g. because it discarded the entry, are rendered as
+// placeholder rather than resolved.
+func captureCrashLine(e *apex.Entry) {
+	b := pCrashBuffer.Load()
+	if b == nil {
+		return
+	}
+	fields := e.Fields
+	copied := false
+	for i, f := range e.Fields {
+		if lazy, ok := f.Value.(*LazyValue); ok && !lazy.resolved.Load() {
+			if !copied {
+				fields = make(apex.Fields, len(e.Fields))
+				copy(fields, e.Fields)
+				copied = true
+			}
+			fields[i] = &apex.Field{Name: f.Name, Value: unresolvedLazy}
+		}
+	}
+	_ = b.handler.HandleLog(&apex.Entry{
+		Fields:    fields,
+		Level:     e.Level,
+		Timestamp: e.Timestamp,
+		Message:   e.Message,
+	})
+}
+
+// resetCrashBuffer discards the captured lines.
+func resetCrashBuffer() {
+	pCrashBuffer.Store(nil)
+}
+
+// DumpCrashBuffer returns the most recent log lines captured in the crash
+// buffer, oldest first, e.g. for attaching them to a crash report. The lines
+// are rendered like the text handler renders them, regardless of the handlers
+// of the loggers that emitted them. Returns nil if the crash buffer is disabled
+// with Config.CrashBuffer.
+func DumpCrashBuffer() []string {
+	b := pCrashBuffer.Load()
+	if b == nil {
+		return nil
+	}
+	return b.dump()
+}
@@ -0,0 +1,74 @@
+package log_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/text"
+)
+
+func TestCrashBuffer(t *testing.T) {
+	// enabled by default
+	log.Info("default")
+	lines := log.DumpCrashBuffer()
+	require.NotEmpty(t, lines)
+	require.Contains(t, lines[len(lines)-1], "default")
+
+	c := log.NewConfig()
+	c.Handler = "discard"
+	c.CrashBuffer = 5
+	log.SetDefault(c)
+	defer log.SetDefault(log.NewConfig())
+
+	for i := 0; i < 8; i++ {
+		log.Info(fmt.Sprintf("message %d", i), "index", i)
+	}
+	// captured regardless of the emitting logger and its handler
+	other := log.New(&log.Config{Level: "debug", Handler: "memory"})
+	other.Debug("other message")
+
+	lines = log.DumpCrashBuffer()
+	require.Equal(t, 5, len(lines))
+	for i, line := range lines[:4] {
+		require.Contains(t, line, fmt.Sprintf("message %d", i+4))
+		require.Contains(t, line, fmt.Sprintf("index=%d", i+4))
+		require.NotContains(t, line, "\n")
+	}
+	require.Contains(t, lines[4], "DEBUG other message")
+
+	// the dump is a copy
+	lines[0] = "modified"
+	require.Contains(t, log.DumpCrashBuffer()[0], "message 4")
+
+	// disabled entries are not captured
+	log.Debug("disabled")
+	require.NotContains(t, log.DumpCrashBuffer()[4], "disabled")
+
+	// lazy values are not resolved for entries discarded by their handler
+	resolved := false
+	log.Info("lazy", "value", log.Lazy(func() interface{} {
+		resolved = true
+		return "resolved"
+	}))
+	require.False(t, resolved)
+	require.Contains(t, log.DumpCrashBuffer()[4], "value=<lazy>")
+
+	// but rendered if resolved by the handler
+	other = log.New(&log.Config{Level: "debug", Handler: "text"})
+	other.SetHandler(text.New(io.Discard))
+	other.Info("lazy", "value", log.Lazy(func() interface{} { return "resolved" }))
+	require.Contains(t, log.DumpCrashBuffer()[4], "value=resolved")
+
+	// cleared by ResetForTest
+	log.ResetForTest()
+	require.Empty(t, log.DumpCrashBuffer())
+
+	c = log.NewConfig()
+	c.CrashBuffer = -1
+	log.SetDefault(c)
+	require.Nil(t, log.DumpCrashBuffer())
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"sync"
+)
+
+// deprecations records the call sites for which a deprecation was logged.
+var deprecations sync.Map
+
+// Deprecated logs a warning that the given functionality is deprecated. The
+// warning is logged only once per call site (file:line) in order to prevent
+// deprecation spam, while still flagging each distinct usage:
+//
+//	lg.Deprecated("Client.Connect - use Client.Dial instead")
+//
+// The entry contains the deprecated functionality as 'deprecated' field and
+// the call site as 'caller' field.
+func (l *Log) Deprecated(what string, fields ...interface{}) {
+	lg := l.get()
+	if !lg.IsWarn() {
+		return
+	}
+
+	pc, file, line, ok := runtime.Caller(1)
+	if !ok {
+		file = "?"
+	}
+	site := fmt.Sprintf("%s:%d", file, line)
+	if _, logged := deprecations.LoadOrStore(site, true); logged {
+		return
+	}
+
+	f := make([]interface{}, 0, len(fields)+4)
+	f = append(f, "deprecated", what)
+	f = append(f, fields...)
+	if lg.config.Caller == nil || !*lg.config.Caller {
+		caller := CallerInfo{File: path.Base(file), Line: line}
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			caller.Func = fn.Name()
+		}
+		f = append(f, "caller", caller)
+	}
+	lg.Warn("deprecated", f...)
+}
@@ -0,0 +1,35 @@
+package log_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+)
+
+func TestDeprecated(t *testing.T) {
+	lg := log.New(&log.Config{
+		Level:   "debug",
+		Handler: "memory",
+	})
+	handler := lg.Handler().(*memory.Handler)
+
+	for i := 0; i < 3; i++ {
+		lg.Deprecated("old api", "index", i)
+	}
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "deprecated", handler.Entries[0].Message)
+	require.Equal(t, "old api", handler.Entries[0].Fields.Get("deprecated"))
+	require.Equal(t, 0, handler.Entries[0].Fields.Get("index"))
+	require.Contains(t, fmt.Sprint(handler.Entries[0].Fields.Get("caller")), "deprecated_test.go:")
+
+	for i := 0; i < 3; i++ {
+		lg.Deprecated("old api", "index", i)
+		lg.Deprecated("old api", "index", i)
+	}
+	require.Equal(t, 3, len(handler.Entries))
+	require.NotEqual(t, handler.Entries[1].Fields.Get("caller"), handler.Entries[2].Fields.Get("caller"))
+}
@@ -0,0 +1,200 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// WithMap returns a Log that adds the given fields to all entries, in the order
+// of their sorted keys, before the fields of the log call. The returned Log
+// shares the configuration and level of this Log and follows its changes, but
+// does not own its output: closing the returned Log has no effect.
+func (l *Log) WithMap(fields map[string]interface{}) *Log {
+	if l.derived != nil {
+		// merge with the base fields of the derived log
+		merged := make(map[string]interface{}, len(l.derived.base)+len(fields))
+		for k, v := range l.derived.base {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		ret := l.derived.parent.WithMap(merged)
+		ret.derived.at = l.derived.at
+		ret.derived.prefix = l.derived.prefix
+		return ret
+	}
+
+	d := &derivedLog{
+		parent: l,
+		base:   fields,
+		fields: make(apex.Fields, 0, len(fields)),
+	}
+	for _, key := range sortedMapKeys(fields) {
+		d.fields = append(d.fields, &apex.Field{Name: key, Value: fields[key]})
+	}
+	return &Log{derived: d}
+}
+
+// At returns a Log that uses the given time as timestamp of all entries instead
+// of the current time, e.g. when replaying or backfilling historical events.
+// Like WithMap, the returned Log follows the configuration and level of this
+// Log.
+func (l *Log) At(t utc.UTC) *Log {
+	d := &derivedLog{
+		parent: l,
+		at:     &t,
+	}
+	if l.derived != nil {
+		d.parent = l.derived.parent
+		d.base = l.derived.base
+		d.fields = l.derived.fields
+		d.prefix = l.derived.prefix
+	}
+	return &Log{derived: d}
+}
+
+// Category returns a Log that tags all entries with a 'category' field holding
+// the given name, e.g. "audit". Categories are orthogonal to severity levels:
+// the entries are logged and filtered at the level of the log call, while
+// handlers may route or filter them based on the category field. Like WithMap,
+// the returned Log follows the configuration and level of this Log.
+func (l *Log) Category(name string) *Log {
+	return l.WithMap(map[string]interface{}{"category": name})
+}
+
+// derivedLog is a log with base fields derived from a parent log.
+type derivedLog struct {
+	parent  *Log
+	base    map[string]interface{}
+	fields  apex.Fields
+	at      *utc.UTC    // the timestamp of all entries - nil for the current time
+	prefix  string      // the message prefix added to the parent's prefix
+	expired atomic.Bool // true if the derived log falls back to its parent
+	cache   atomic.Pointer[derivedLogger]
+}
+
+// derivedLogger is the logger of a derived log created from the source logger
+// of the parent log.
+type derivedLogger struct {
+	source *logger
+	logger *logger
+}
+
+// get returns the logger of the derived log, re-creating it if the logger of
+// the parent log changed.
+func (d *derivedLog) get() *logger {
+	source := d.parent.get()
+	if d.expired.Load() {
+		return source
+	}
+	if c := d.cache.Load(); c != nil && c.source == source {
+		return c.logger
+	}
+	lg := source.copy(func(lg *logger) {
+		if d.at != nil {
+			al := lg.logger()
+			al.Handler = &timestampHandler{Handler: al.Handler, at: d.at.Time}
+		}
+		lg.log = lg.log.WithFields(d.fields)
+		if d.prefix != "" {
+			c := *lg.config
+			c.Prefix = strings.TrimSpace(c.Prefix + " " + d.prefix)
+			lg.config = &c
+		}
+		lg.file = nil
+		lg.gzip = nil
+	})
+	d.cache.Store(&derivedLogger{source: source, logger: lg})
+	return lg
+}
+
+// timestampHandler sets a fixed timestamp on all entries before passing them to
+// the wrapped handler.
+type timestampHandler struct {
+	apex.Handler
+	at time.Time
+}
+
+func (h *timestampHandler) HandleLog(e *apex.Entry) error {
+	e.Timestamp = h.at
+	return h.Handler.HandleLog(e)
+}
+
+// Asynchronous returns whether the wrapped handler is asynchronous.
+func (h *timestampHandler) Asynchronous() bool {
+	if a, ok := h.Handler.(apex.Asynchronous); ok {
+		return a.Asynchronous()
+	}
+	return false
+}
+
+func (h *timestampHandler) unwrap() apex.Handler {
+	return h.Handler
+}
+
+// expandMaps returns the given fields with all maps in key position replaced
+// by their key-value pairs in the order of their sorted keys.
+func expandMaps(args []interface{}) []interface{} {
+	var ret []interface{}
+	walkFields(args, func(start, end int) bool {
+		if m, ok := args[start].(map[string]interface{}); ok {
+			if ret == nil {
+				ret = make([]interface{}, 0, len(args)+2*len(m))
+				ret = append(ret, args[:start]...)
+			}
+			for _, key := range sortedMapKeys(m) {
+				ret = append(ret, key, m[key])
+			}
+		} else if ret != nil {
+			ret = append(ret, args[start:end]...)
+		}
+		return true
+	})
+	if ret == nil {
+		return args
+	}
+	return ret
+}
+
+// walkFields calls fn with the start and end index of each field in the given
+// fields of a log call, until fn returns false. The fields are parsed like
+// apex does: errors, apex fields and maps (expanded by expandMaps) have no key
+// and span a single element, any other value is a key followed by its value.
+// A trailing key without value spans a single element.
+func walkFields(args []interface{}, fn func(start, end int) bool) {
+	for start := 0; start < len(args); {
+		end := start + 1
+		if !isKeyless(args[start]) && end < len(args) {
+			end++
+		}
+		if !fn(start, end) {
+			return
+		}
+		start = end
+	}
+}
+
+// isKeyless returns true if the given element of the fields of a log call is
+// a field without key, see walkFields.
+func isKeyless(arg interface{}) bool {
+	switch arg.(type) {
+	case error, apex.Fielder, apex.Field, *apex.Field, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
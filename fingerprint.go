@@ -0,0 +1,156 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eluv-io/utc-go"
+)
+
+var (
+	// pFingerprints is a pointer to the global fingerprint limiter - nil if disabled
+	pFingerprints atomic.Pointer[fingerprintLimiter]
+)
+
+// SetErrorFingerprintWindow enables the global rate limiting of identical
+// errors: regardless of the logger they are logged with, entries at Error level
+// with the same fingerprint are emitted at most once per window. The
+// fingerprint of an entry is its message combined with the text of the error
+// values in its fields. Entries without error values are not limited.
+//
+// The number of entries suppressed within a window is added as 'suppressed'
+// field to the next emitted entry with the same fingerprint if it occurs within
+// the following window. Otherwise the count is dropped, so that the limiter
+// does not accumulate fingerprints that never recur, e.g. errors with IDs.
+//
+// A window <= 0 disables the limiter. An optional clock may be provided as time
+// source for the windows, e.g. for testing. Default: utc.Now
+func SetErrorFingerprintWindow(window time.Duration, clock ...func() utc.UTC) {
+	if window <= 0 {
+		pFingerprints.Store(nil)
+		return
+	}
+	now := utc.Now
+	if len(clock) > 0 && clock[0] != nil {
+		now = clock[0]
+	}
+	pFingerprints.Store(newFingerprintLimiter(window, now))
+}
+
+// limitErrors applies the global fingerprint limiter to the given error entry.
+// Returns false if the entry has to be suppressed, otherwise the (potentially
+// extended) fields.
+func limitErrors(msg string, fields []interface{}) ([]interface{}, bool) {
+	fl := pFingerprints.Load()
+	if fl == nil {
+		return fields, true
+	}
+	return fl.limit(msg, fields)
+}
+
+// =============================================================================
+
+type fingerprintLimiter struct {
+	mutex     sync.Mutex
+	window    time.Duration
+	now       func() utc.UTC
+	entries   map[string]*fingerprintEntry
+	lastPurge utc.UTC
+}
+
+type fingerprintEntry struct {
+	emitted    utc.UTC // time the entry was last emitted
+	suppressed int     // number of entries suppressed since then
+}
+
+func newFingerprintLimiter(window time.Duration, now func() utc.UTC) *fingerprintLimiter {
+	return &fingerprintLimiter{
+		window:    window,
+		now:       now,
+		entries:   make(map[string]*fingerprintEntry),
+		lastPurge: now(),
+	}
+}
+
+func (f *fingerprintLimiter) limit(msg string, fields []interface{}) ([]interface{}, bool) {
+	fp, ok := fingerprint(msg, fields)
+	if !ok {
+		return fields, true
+	}
+
+	now := f.now()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.purge(now)
+
+	e, found := f.entries[fp]
+	if !found {
+		f.entries[fp] = &fingerprintEntry{emitted: now}
+		return fields, true
+	}
+	if now.Sub(e.emitted) < f.window {
+		e.suppressed++
+		return fields, false
+	}
+
+	suppressed := e.suppressed
+	e.emitted = now
+	e.suppressed = 0
+	if suppressed == 0 {
+		return fields, true
+	}
+
+	ret := make([]interface{}, 0, len(fields)+2)
+	ret = append(ret, fields...)
+	ret = append(ret, "suppressed", suppressed)
+	return ret, true
+}
+
+// purge removes expired entries. Entries with suppressed occurrences are kept
+// for another window, giving the next occurrence the chance to report them;
+// after that, their count is dropped. Runs at most once per window.
+func (f *fingerprintLimiter) purge(now utc.UTC) {
+	if now.Sub(f.lastPurge) < f.window {
+		return
+	}
+	f.lastPurge = now
+	for fp, e := range f.entries {
+		age := now.Sub(e.emitted)
+		if age >= 2*f.window || (e.suppressed == 0 && age >= f.window) {
+			delete(f.entries, fp)
+		}
+	}
+}
+
+// fingerprint computes the fingerprint of the given log message and fields.
+// Returns false if the fields do not contain any error.
+func fingerprint(msg string, fields []interface{}) (string, bool) {
+	if len(fields) == 1 {
+		if slice, ok := fields[0].([]interface{}); ok {
+			// same as apex: the ellipsis was forgotten in the log call
+			fields = slice
+		}
+	}
+
+	var sb *strings.Builder
+	for _, field := range fields {
+		err, ok := field.(error)
+		if !ok || err == nil {
+			continue
+		}
+		if sb == nil {
+			sb = &strings.Builder{}
+			sb.WriteString(msg)
+		}
+		sb.WriteString("\n")
+		sb.WriteString(err.Error())
+	}
+	if sb == nil {
+		return "", false
+	}
+	return sb.String(), true
+}
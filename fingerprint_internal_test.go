@@ -0,0 +1,39 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/utc-go"
+)
+
+func TestFingerprintPurge(t *testing.T) {
+	now := utc.UnixMilli(0)
+	fl := newFingerprintLimiter(time.Minute, func() utc.UTC { return now })
+
+	// many distinct fingerprints, each repeated once and then never again
+	for i := 0; i < 1000; i++ {
+		err := fmt.Errorf("request %d failed", i)
+		_, ok := fl.limit("failed", []interface{}{err})
+		require.True(t, ok)
+		_, ok = fl.limit("failed", []interface{}{err})
+		require.False(t, ok)
+	}
+	require.Equal(t, 1000, len(fl.entries))
+
+	// kept for another window to report the suppressed count...
+	now = now.Add(time.Minute)
+	fields, ok := fl.limit("failed", []interface{}{fmt.Errorf("request %d failed", 0)})
+	require.True(t, ok)
+	require.Equal(t, 1, fields[len(fields)-1])
+	require.Equal(t, 1000, len(fl.entries))
+
+	// ...and dropped after that
+	now = now.Add(time.Minute)
+	_, ok = fl.limit("failed", []interface{}{fmt.Errorf("other")})
+	require.True(t, ok)
+	require.Equal(t, 1, len(fl.entries))
+}
@@ -0,0 +1,84 @@
+package log_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestErrorFingerprintWindow(t *testing.T) {
+	now := utc.UnixMilli(0)
+	defer utc.MockNow(now)()
+
+	log.SetDefault(&log.Config{
+		Level:   "debug",
+		Handler: "memory",
+	})
+	log.SetErrorFingerprintWindow(time.Minute)
+	defer log.SetErrorFingerprintWindow(0)
+
+	handler := log.Root().Handler().(*memory.Handler)
+	handler.Entries = nil
+
+	a := log.Get("/fingerprint/a")
+	b := log.Get("/fingerprint/b")
+	err := errors.E("op", errors.K.IO, io.EOF)
+
+	a.Error("failed", err)
+	b.Error("failed", err)
+	a.Error("failed", err)
+	b.Error("failed", "error", err)
+	require.Equal(t, 1, len(handler.Entries))
+	require.Nil(t, handler.Entries[0].Fields.Get("suppressed"))
+
+	// different fingerprints are not affected
+	a.Error("failed", io.ErrUnexpectedEOF)
+	a.Error("other", err)
+	a.Error("no error", "key", "value")
+	a.Error("no error", "key", "value")
+	require.Equal(t, 5, len(handler.Entries))
+
+	defer utc.MockNow(now.Add(time.Minute))()
+	b.Error("failed", err)
+	require.Equal(t, 6, len(handler.Entries))
+	require.Equal(t, "/fingerprint/b", handler.Entries[5].Fields.Get("logger"))
+	require.Equal(t, 3, handler.Entries[5].Fields.Get("suppressed"))
+
+	a.Error("failed", err)
+	require.Equal(t, 6, len(handler.Entries))
+}
+
+func TestErrorFingerprintWindowClock(t *testing.T) {
+	now := utc.UnixMilli(0)
+	clock := func() utc.UTC { return now }
+
+	log.SetDefault(&log.Config{
+		Level:   "debug",
+		Handler: "memory",
+	})
+	log.SetErrorFingerprintWindow(time.Minute, clock)
+	defer log.SetErrorFingerprintWindow(0)
+
+	handler := log.Root().Handler().(*memory.Handler)
+	handler.Entries = nil
+
+	a := log.Get("/fingerprint/a")
+	err := errors.E("op", errors.K.IO, io.EOF)
+
+	a.Error("failed", err)
+	now = now.Add(59 * time.Second)
+	a.Error("failed", err)
+	require.Equal(t, 1, len(handler.Entries))
+
+	now = now.Add(time.Second)
+	a.Error("failed", err)
+	require.Equal(t, 2, len(handler.Entries))
+	require.Equal(t, 1, handler.Entries[1].Fields.Get("suppressed"))
+}
@@ -0,0 +1,213 @@
+// Package audit implements a json handler writing tamper-evident audit logs.
+//
+// Entries are chained: each line contains the hash of the previous line as
+// 'prev_hash' and its own hash as 'hash', computed as the hex encoded SHA-256
+// of the line without the 'hash' key. Modifying, inserting or removing a line
+// breaks the chain, which is detected by Verify.
+//
+// A handler appending to an existing audit log continues its chain if seeded
+// with the hash of the last line using WithPrevHash and LastHash. Handlers
+// writing to the same output share a single chain with WithChain. A file that
+// continues the chain of another file, e.g. after rotation, is verified with
+// VerifyFrom and the last hash of the previous file.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+)
+
+// Default handler outputting to stderr.
+var Default = New(os.Stderr)
+
+// Handler implementation.
+type Handler struct {
+	Writer io.Writer
+	chain  *Chain
+}
+
+// New creates a new audit handler. The chain starts with an empty 'prev_hash'
+// unless seeded with WithPrevHash.
+func New(w io.Writer) *Handler {
+	return &Handler{
+		Writer: w,
+		chain:  NewChain(""),
+	}
+}
+
+// WithPrevHash sets the hash of the previous line, continuing an existing
+// chain.
+func (h *Handler) WithPrevHash(hash string) *Handler {
+	h.chain.mu.Lock()
+	defer h.chain.mu.Unlock()
+	h.chain.prevHash = hash
+	return h
+}
+
+// WithChain makes the handler continue the given chain, which may be shared
+// with other handlers writing to the same output.
+func (h *Handler) WithChain(c *Chain) *Handler {
+	h.chain = c
+	return h
+}
+
+// Chain is the state of a hash chain: the hash of the last line. Handlers
+// sharing a chain serialize their writes, so that their lines form a single
+// chain.
+type Chain struct {
+	mu       sync.Mutex
+	prevHash string
+}
+
+// NewChain creates a chain continuing the line with the given hash, or a new
+// chain if the hash is empty.
+func NewChain(prevHash string) *Chain {
+	return &Chain{prevHash: prevHash}
+}
+
+// LastHash returns the hash of the last line of the audit log in the given
+// file, or an empty string if the file does not exist or is empty.
+func LastHash(path string) (string, error) {
+	e := errors.Template("audit.LastHash", errors.K.Invalid, "path", path)
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", e(errors.K.IO, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := newScanner(f)
+	var last []byte
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", e(errors.K.IO, err)
+	}
+	if last == nil {
+		return "", nil
+	}
+	var rec record
+	err = json.Unmarshal(last, &rec)
+	if err != nil {
+		return "", e(err)
+	}
+	return rec.Hash, nil
+}
+
+// newScanner returns a line scanner for audit logs.
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return scanner
+}
+
+// record is the json representation of a log entry.
+type record struct {
+	Fields    json.RawMessage `json:"fields"`
+	Level     string          `json:"level"`
+	Timestamp string          `json:"timestamp"`
+	Message   string          `json:"message"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+// hash computes the hash of the record without its 'hash' key.
+func (r record) hash() (string, error) {
+	r.Hash = ""
+	bts, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bts)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	fields, err := json.Marshal(e.Fields)
+	if err != nil {
+		return err
+	}
+
+	c := h.chain
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rec := record{
+		Fields:    fields,
+		Level:     e.Level.String(),
+		Timestamp: timestamp.Of(e).String(),
+		Message:   e.Message,
+		PrevHash:  c.prevHash,
+	}
+	rec.Hash, err = rec.hash()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.Writer.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	c.prevHash = rec.Hash
+	return nil
+}
+
+// Verify reads an audit log and verifies the hash chain, which must start with
+// an empty 'prev_hash'. Returns an error identifying the first line that does
+// not match.
+func Verify(r io.Reader) error {
+	return VerifyFrom(r, "")
+}
+
+// VerifyFrom is like Verify, but for an audit log continuing the chain of the
+// previous line with the given hash, e.g. the last hash of the previous file
+// after rotation as returned by LastHash.
+func VerifyFrom(r io.Reader, prevHash string) error {
+	e := errors.Template("audit.Verify", errors.K.Invalid)
+
+	scanner := newScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec record
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			return e(err, "line", line)
+		}
+		if rec.PrevHash != prevHash {
+			return e("reason", "previous hash mismatch", "line", line)
+		}
+		hash, err := rec.hash()
+		if err != nil {
+			return e(err, "line", line)
+		}
+		if rec.Hash != hash {
+			return e("reason", "hash mismatch", "line", line)
+		}
+		prevHash = rec.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return e(err, "line", line)
+	}
+	return nil
+}
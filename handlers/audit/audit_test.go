@@ -0,0 +1,100 @@
+package audit_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/log-go/handlers/audit"
+)
+
+func TestChain(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := &apex.Logger{
+		Handler: audit.New(buf),
+		Level:   apex.InfoLevel,
+	}
+	lg.Info("user created", "user", "alice")
+	lg.Info("role granted", "user", "alice", "role", "admin")
+	lg.Warn("user deleted", "user", "bob")
+
+	require.NoError(t, audit.Verify(strings.NewReader(buf.String())))
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	require.Equal(t, 4, len(lines)) // 3 lines plus empty remainder
+	require.Contains(t, lines[0], `"prev_hash":""`)
+
+	// modifying a middle entry breaks the chain
+	modified := lines[0] + strings.Replace(lines[1], `"admin"`, `"guest"`, 1) + lines[2]
+	err := audit.Verify(strings.NewReader(modified))
+	require.Error(t, err)
+	require.True(t, errors.IsKind(errors.K.Invalid, err))
+	require.Equal(t, 2, err.(*errors.Error).Field("line"))
+
+	// removing an entry breaks the chain
+	err = audit.Verify(strings.NewReader(lines[0] + lines[2]))
+	require.Error(t, err)
+	require.Equal(t, 2, err.(*errors.Error).Field("line"))
+}
+
+func TestSharedChain(t *testing.T) {
+	buf := &bytes.Buffer{}
+	chain := audit.NewChain("")
+	a := &apex.Logger{Handler: audit.New(buf).WithChain(chain), Level: apex.InfoLevel}
+	b := &apex.Logger{Handler: audit.New(buf).WithChain(chain), Level: apex.InfoLevel}
+	a.Info("first")
+	b.Info("second")
+	a.Info("third")
+	require.NoError(t, audit.Verify(strings.NewReader(buf.String())))
+}
+
+func TestAppend(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+
+	hash, err := audit.LastHash(file)
+	require.NoError(t, err)
+	require.Equal(t, "", hash)
+
+	logTo := func(file string, prevHash string, msg string) {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, f.Close()) }()
+		lg := &apex.Logger{
+			Handler: audit.New(f).WithPrevHash(prevHash),
+			Level:   apex.InfoLevel,
+		}
+		lg.Info(msg)
+	}
+	verify := func(file string, prevHash string) error {
+		bts, err := os.ReadFile(file)
+		require.NoError(t, err)
+		return audit.VerifyFrom(bytes.NewReader(bts), prevHash)
+	}
+
+	logTo(file, "", "first")
+
+	// a second handler seeded with the last hash continues the chain
+	hash, err = audit.LastHash(file)
+	require.NoError(t, err)
+	require.NotEqual(t, "", hash)
+	logTo(file, hash, "second")
+	require.NoError(t, verify(file, ""))
+
+	// an unseeded handler breaks the chain
+	logTo(file, "", "third")
+	require.Error(t, verify(file, ""))
+
+	// a rotated file is verified from the last hash of the previous file
+	rotated := filepath.Join(t.TempDir(), "audit.log")
+	hash, err = audit.LastHash(file)
+	require.NoError(t, err)
+	logTo(rotated, hash, "fourth")
+	require.Error(t, verify(rotated, ""))
+	require.NoError(t, verify(rotated, hash))
+}
@@ -0,0 +1,99 @@
+// Package cloudevents implements a json handler wrapping each log entry in a
+// CloudEvents 1.0 envelope.
+//
+// See https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/spec.md
+package cloudevents
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+)
+
+// Default handler outputting to stderr.
+var Default = New(os.Stderr)
+
+// TypePrefix is the prefix of the event type, which is completed with the log
+// level, e.g. "io.eluv.log.warn".
+const TypePrefix = "io.eluv.log."
+
+// Handler implementation.
+type Handler struct {
+	mu     sync.Mutex
+	Writer io.Writer
+}
+
+// New creates a new cloudevents handler.
+func New(w io.Writer) *Handler {
+	return &Handler{
+		Writer: w,
+	}
+}
+
+// event is the CloudEvents representation of a log entry.
+type event struct {
+	SpecVersion     string `json:"specversion"`
+	Type            string `json:"type"`
+	Source          string `json:"source"`
+	ID              string `json:"id"`
+	Time            string `json:"time"`
+	DataContentType string `json:"datacontenttype"`
+	Data            data   `json:"data"`
+}
+
+type data struct {
+	Message string     `json:"message"`
+	Fields  log.Fields `json:"fields"`
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	source, _ := e.Fields.Get("logger").(string)
+	if source == "" {
+		source = "/"
+	}
+	fields := e.Fields
+	if fields == nil {
+		fields = log.Fields{}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(&event{
+		SpecVersion:     "1.0",
+		Type:            TypePrefix + e.Level.String(),
+		Source:          source,
+		ID:              newID(),
+		Time:            timestamp.Of(e).String(),
+		DataContentType: "application/json",
+		Data: data{
+			Message: e.Message,
+			Fields:  fields,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err = h.Writer.Write(buf.Bytes())
+	return err
+}
+
+// newID returns a random event id.
+func newID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
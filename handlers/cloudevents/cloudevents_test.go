@@ -0,0 +1,61 @@
+package cloudevents_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/cloudevents"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestHandler(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+	fls := false
+
+	log.SetDefault(&log.Config{
+		Level:       "info",
+		Handler:     "cloudevents",
+		GoRoutineID: &fls,
+	})
+	defer log.SetDefault(log.NewConfig())
+
+	lg := log.Get("/audit/users")
+	handler, ok := lg.Handler().(*cloudevents.Handler)
+	require.True(t, ok)
+	buf := &bytes.Buffer{}
+	handler.Writer = buf
+
+	lg.Info("user created", "user", "alice")
+	lg.Warn("user deleted", "user", "bob")
+
+	wantTypes := []string{"io.eluv.log.info", "io.eluv.log.warn"}
+	wantMessages := []string{"user created", "user deleted"}
+	wantUsers := []string{"alice", "bob"}
+
+	sc := bufio.NewScanner(buf)
+	ids := map[interface{}]bool{}
+	count := 0
+	for ; sc.Scan(); count++ {
+		m := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &m))
+
+		require.Equal(t, "1.0", m["specversion"])
+		require.Equal(t, wantTypes[count], m["type"])
+		require.Equal(t, "/audit/users", m["source"])
+		require.NotEmpty(t, m["id"])
+		ids[m["id"]] = true
+		require.Equal(t, "1970-01-01T00:00:00.000Z", m["time"])
+		require.Equal(t, "application/json", m["datacontenttype"])
+		require.Equal(t, map[string]interface{}{
+			"message": wantMessages[count],
+			"fields":  map[string]interface{}{"logger": "/audit/users", "user": wantUsers[count]},
+		}, m["data"])
+	}
+	require.Equal(t, 2, count)
+	require.Equal(t, 2, len(ids))
+}
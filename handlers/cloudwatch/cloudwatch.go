@@ -0,0 +1,86 @@
+// Package cloudwatch implements a json handler producing flat json objects
+// suitable for querying with AWS CloudWatch Logs Insights.
+//
+// Fields are hoisted to the top level of the json object next to the
+// 'timestamp' (epoch millis), 'level' and 'message' keys. Fields colliding with
+// these keys are prefixed with "fields.".
+package cloudwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+)
+
+// Default handler outputting to stderr.
+var Default = New(os.Stderr)
+
+// Handler implementation.
+type Handler struct {
+	mu     sync.Mutex
+	Writer io.Writer
+}
+
+// New creates a new cloudwatch handler.
+func New(w io.Writer) *Handler {
+	return &Handler{
+		Writer: w,
+	}
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	buf.WriteString(`{"timestamp":`)
+	buf.WriteString(strconv.FormatInt(timestamp.Of(e).UnixMilli(), 10))
+	buf.WriteString(`,"level":`)
+	if err := writeValue(buf, enc, e.Level.String()); err != nil {
+		return err
+	}
+	buf.WriteString(`,"message":`)
+	if err := writeValue(buf, enc, e.Message); err != nil {
+		return err
+	}
+	for _, field := range e.Fields {
+		name := field.Name
+		switch name {
+		case "timestamp", "level", "message":
+			name = "fields." + name
+		}
+		buf.WriteByte(',')
+		if err := writeValue(buf, enc, name); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := writeValue(buf, enc, field.Value); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("}\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := h.Writer.Write(buf.Bytes())
+	return err
+}
+
+// writeValue writes the json encoding of the given value without the trailing
+// newline added by the encoder.
+func writeValue(buf *bytes.Buffer, enc *json.Encoder, val interface{}) error {
+	err := enc.Encode(val)
+	if err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
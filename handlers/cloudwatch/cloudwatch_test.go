@@ -0,0 +1,46 @@
+package cloudwatch_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/cloudwatch"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestHandler(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(1234567))()
+	fls := false
+
+	lg := log.New(&log.Config{
+		Level:       "debug",
+		Handler:     "cloudwatch",
+		GoRoutineID: &fls,
+	})
+	handler, ok := lg.Handler().(*cloudwatch.Handler)
+	require.True(t, ok)
+	buf := &bytes.Buffer{}
+	handler.Writer = buf
+
+	lg.Info("info message", "account_id", "acc1", "count", 3, "message", "clash")
+	lg.Warn("warn message", "nested", map[string]int{"a": 1})
+
+	require.Equal(t, ""+
+		`{"timestamp":1234567,"level":"info","message":"info message","logger":"/","account_id":"acc1","count":3,"fields.message":"clash"}`+"\n"+
+		`{"timestamp":1234567,"level":"warn","message":"warn message","logger":"/","nested":{"a":1}}`+"\n",
+		buf.String())
+
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	require.True(t, sc.Scan())
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(sc.Bytes(), &m))
+	require.Equal(t, float64(1234567), m["timestamp"])
+	require.Equal(t, "info", m["level"])
+	require.Equal(t, "acc1", m["account_id"])
+	require.Equal(t, float64(3), m["count"])
+}
@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/internal/bufpool"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+	"github.com/eluv-io/log-go/handlers/internal/values"
 	"github.com/eluv-io/utc-go"
 )
 
 // Default handler outputting to stderr.
 var Default = New(os.Stderr)
 
+// processStart is the process-wide default baseline for the offsets in the log
+// output, set once at package initialization. Using the same baseline for all
+// handlers keeps offsets monotonic when handlers are re-created, e.g. on
+// config reloads.
+var processStart = utc.Now()
+
 // colors.
 const (
 	red     = 31
@@ -66,16 +74,33 @@ type Handler struct {
 	mu            sync.Mutex
 	Writer        io.Writer
 	useTimestamps bool
+	useBoth       bool
+	jsonValues    bool
+	jsonArrays    bool
+	fieldColors   map[string]int // copy-on-write
+	source        *sourceContext // nil if disabled
 }
 
-// New creates a new console handler.
+// New creates a new console handler. Colors are disabled if the NO_COLOR
+// environment variable is set to a non-empty value (see https://no-color.org),
+// unless enabled explicitly with WithColor.
 func New(w io.Writer) *Handler {
 	return &Handler{
-		start:  utc.Now(),
-		Writer: w,
+		start:   processStart,
+		noColor: os.Getenv("NO_COLOR") != "",
+		Writer:  w,
 	}
 }
 
+// WithStart sets the start time used as baseline for the offsets in the log
+// output. Default: the start of the process
+func (h *Handler) WithStart(start utc.UTC) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.start = start
+	return h
+}
+
 // WithTimestamps enables or disables timestamps instead of offsets in the log output.
 func (h *Handler) WithTimestamps(use bool) *Handler {
 	h.mu.Lock()
@@ -84,7 +109,18 @@ func (h *Handler) WithTimestamps(use bool) *Handler {
 	return h
 }
 
-// WithColor enables or disables colored log output.
+// WithOffsetAndTimestamp enables or disables printing both the offset and
+// the timestamp in the log output, formatted as "offset (timestamp)". When
+// enabled, it takes precedence over WithTimestamps.
+func (h *Handler) WithOffsetAndTimestamp(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.useBoth = use
+	return h
+}
+
+// WithColor enables or disables colored log output, overriding the NO_COLOR
+// environment variable.
 func (h *Handler) WithColor(colored bool) *Handler {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -92,46 +128,131 @@ func (h *Handler) WithColor(colored bool) *Handler {
 	return h
 }
 
+// WithJSONValues enables or disables rendering of complex field values
+// (structs and maps) as compact json instead of Go's default format.
+func (h *Handler) WithJSONValues(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.jsonValues = use
+	return h
+}
+
+// WithJSONArrays enables or disables rendering of slice and array field values
+// as compact json arrays (e.g. ["arg1","arg2"]) instead of Go's default format
+// (e.g. [arg1 arg2]), consistent with the json handlers.
+func (h *Handler) WithJSONArrays(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.jsonArrays = use
+	return h
+}
+
+// WithFieldColor sets the ANSI color used for rendering the values of fields
+// with the given name, regardless of the entry's level, e.g. to make errors
+// stand out:
+//
+//	h.WithFieldColor("error", 31)
+//
+// Values of other fields are rendered in the level color. A negative color
+// removes the override.
+func (h *Handler) WithFieldColor(field string, color int) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fc := make(map[string]int, len(h.fieldColors)+1)
+	for name, c := range h.fieldColors {
+		fc[name] = c
+	}
+	if color < 0 {
+		delete(fc, field)
+	} else {
+		fc[field] = color
+	}
+	h.fieldColors = fc
+	return h
+}
+
 // HandleLog implements log.Handler.
 func (h *Handler) HandleLog(e *log.Entry) error {
 
-	sb := &strings.Builder{}
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
 
 	color := Colors[e.Level]
 	intensity := Intensities[e.Level]
 	colored := !h.noColor
 	level := Levels[e.Level]
 
-	var timestamp string
-	if h.useTimestamps {
-		timestamp = utc.Now().String()
-	} else {
-		d := utc.Since(h.start)
-		ts := d / time.Second
-		tms := (d - ts*time.Second) / time.Millisecond
-		timestamp = fmt.Sprintf("% 4d.%03d", ts, tms)
+	ts := timestamp.Of(e)
+	var stamp string
+	switch {
+	case h.useBoth:
+		stamp = fmt.Sprintf("%s (%s)", h.offset(ts), ts.String())
+	case h.useTimestamps:
+		stamp = ts.String()
+	default:
+		stamp = h.offset(ts)
 	}
 
 	if colored {
-		_, _ = fmt.Fprintf(sb, "%s \033[%d;%dm%-5s\033[0m %-20s", timestamp, intensity, color, level, e.Message)
+		_, _ = fmt.Fprintf(buf, "%s \033[%d;%dm%-5s\033[0m %-20s", stamp, intensity, color, level, e.Message)
 	} else {
-		_, _ = fmt.Fprintf(sb, "%s %-5s %-20s", timestamp, level, e.Message)
+		_, _ = fmt.Fprintf(buf, "%s %-5s %-20s", stamp, level, e.Message)
 	}
 
+	fieldColors := h.fieldColors
+	source := h.source
 	for _, field := range e.Fields {
+		value := h.value(field.Value)
+		if source != nil {
+			switch val := values.Resolve(field.Value).(type) {
+			case error:
+				value = source.apply(val.Error())
+			case string:
+				value = source.apply(val)
+			}
+		}
 		if colored {
-			_, _ = fmt.Fprintf(sb, " %s=\033[%d;%dm%v\033[0m", field.Name, intensity, color, field.Value)
+			fieldColor, ok := fieldColors[field.Name]
+			if !ok {
+				fieldColor = color
+			}
+			_, _ = fmt.Fprintf(buf, " %s=\033[%d;%dm%v\033[0m", field.Name, intensity, fieldColor, value)
 		} else {
-			_, _ = fmt.Fprintf(sb, " %s=%v", field.Name, field.Value)
+			_, _ = fmt.Fprintf(buf, " %s=%v", field.Name, value)
 		}
 	}
 
-	_, _ = fmt.Fprintln(sb)
+	_, _ = fmt.Fprintln(buf)
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	_, _ = h.Writer.Write([]byte(sb.String()))
+	_, _ = h.Writer.Write(buf.Bytes())
 
 	return nil
 }
+
+// value returns the value to render for the given field value.
+func (h *Handler) value(val interface{}) interface{} {
+	val = values.Resolve(val)
+	if h.jsonValues {
+		if s, ok := values.JSON(val); ok {
+			return s
+		}
+	}
+	if h.jsonArrays {
+		if s, ok := values.JSONArray(val); ok {
+			return s
+		}
+	}
+	return val
+}
+
+// offset returns the time elapsed between the handler's start and the given
+// timestamp, formatted as seconds with millisecond precision.
+func (h *Handler) offset(at utc.UTC) string {
+	d := at.Sub(h.start)
+	ts := d / time.Second
+	tms := (d - ts*time.Second) / time.Millisecond
+	return fmt.Sprintf("% 4d.%03d", ts, tms)
+}
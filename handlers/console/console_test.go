@@ -3,6 +3,7 @@ package console_test
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -72,6 +73,19 @@ func TestHandler(t *testing.T) {
 				"1970-01-01T00:00:00.000Z WARN  warn message         field1=value1 field2=value2\n" +
 				"1970-01-01T00:00:00.000Z ERR!  error message        field1=value1 field2=value2\n",
 		},
+		{
+			name:   "offset and timestamp, no color",
+			caller: &falseVal,
+			adapt: func(h *console.Handler) {
+				h.WithOffsetAndTimestamp(true).WithColor(false)
+			},
+			want: "" +
+				"   0.000 (1970-01-01T00:00:00.000Z) TRCE  trace message        field1=value1 field2=value2\n" +
+				"   0.000 (1970-01-01T00:00:00.000Z) DBG   debug message        field1=value1 field2=value2\n" +
+				"   0.000 (1970-01-01T00:00:00.000Z)       info message         field1=value1 field2=value2\n" +
+				"   0.000 (1970-01-01T00:00:00.000Z) WARN  warn message         field1=value1 field2=value2\n" +
+				"   0.000 (1970-01-01T00:00:00.000Z) ERR!  error message        field1=value1 field2=value2\n",
+		},
 		{
 			name:   "timestamp, color, caller",
 			caller: &trueVal,
@@ -79,11 +93,11 @@ func TestHandler(t *testing.T) {
 				h.WithTimestamps(true).WithColor(false)
 			},
 			want: "" +
-				"1970-01-01T00:00:00.000Z TRCE  trace message        field1=value1 field2=value2 caller=console_test.go:108\n" +
-				"1970-01-01T00:00:00.000Z DBG   debug message        field1=value1 field2=value2 caller=console_test.go:109\n" +
-				"1970-01-01T00:00:00.000Z       info message         field1=value1 field2=value2 caller=console_test.go:110\n" +
-				"1970-01-01T00:00:00.000Z WARN  warn message         field1=value1 field2=value2 caller=console_test.go:111\n" +
-				"1970-01-01T00:00:00.000Z ERR!  error message        field1=value1 field2=value2 caller=console_test.go:112\n",
+				"1970-01-01T00:00:00.000Z TRCE  trace message        field1=value1 field2=value2 caller=console_test.go:122\n" +
+				"1970-01-01T00:00:00.000Z DBG   debug message        field1=value1 field2=value2 caller=console_test.go:123\n" +
+				"1970-01-01T00:00:00.000Z       info message         field1=value1 field2=value2 caller=console_test.go:124\n" +
+				"1970-01-01T00:00:00.000Z WARN  warn message         field1=value1 field2=value2 caller=console_test.go:125\n" +
+				"1970-01-01T00:00:00.000Z ERR!  error message        field1=value1 field2=value2 caller=console_test.go:126\n",
 		},
 	}
 
@@ -103,7 +117,7 @@ func TestHandler(t *testing.T) {
 
 			buf := &bytes.Buffer{}
 			handler.Writer = buf
-			test.adapt(handler)
+			test.adapt(handler.WithStart(utc.Now()))
 
 			lg.Trace("trace message", "field1", "value1", "field2", "value2")
 			lg.Debug("debug message", "field1", "value1", "field2", "value2")
@@ -116,3 +130,87 @@ func TestHandler(t *testing.T) {
 	}
 
 }
+
+func TestConsoleStart(t *testing.T) {
+	now := utc.UnixMilli(10_000)
+	defer utc.MockNow(now)()
+
+	start := now.Add(-1500 * time.Millisecond)
+	falseVal := false
+	lg := log.New(&log.Config{
+		Level:        "info",
+		Handler:      "console",
+		GoRoutineID:  &falseVal,
+		ConsoleStart: &start,
+	})
+	buf := &bytes.Buffer{}
+	lg.Handler().(*console.Handler).WithColor(false).Writer = buf
+
+	lg.Info("message")
+	require.Equal(t, "   1.500       message             \n", buf.String())
+}
+
+func TestFieldColor(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	falseVal := false
+	lg := log.New(&log.Config{
+		Level:       "info",
+		Handler:     "console",
+		GoRoutineID: &falseVal,
+	})
+	buf := &bytes.Buffer{}
+	h := lg.Handler().(*console.Handler).WithStart(utc.Now()).WithFieldColor("error", 31)
+	h.Writer = buf
+
+	lg.Info("message", "error", "failed", "status", 500)
+	require.Equal(t, "   0.000 \033[0;34m     \033[0m message              "+
+		"error=\033[0;31mfailed\033[0m status=\033[0;34m500\033[0m\n", buf.String())
+
+	buf.Reset()
+	h.WithFieldColor("error", -1)
+	lg.Info("message", "error", "failed")
+	require.Equal(t, "   0.000 \033[0;34m     \033[0m message              "+
+		"error=\033[0;34mfailed\033[0m\n", buf.String())
+}
+
+func TestJSONArrays(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	falseVal := false
+	lg := log.New(&log.Config{
+		Level:       "info",
+		Handler:     "console",
+		GoRoutineID: &falseVal,
+	})
+	buf := &bytes.Buffer{}
+	h := lg.Handler().(*console.Handler).WithStart(utc.Now()).WithColor(false).WithJSONArrays(true)
+	h.Writer = buf
+
+	lg.Info("message", "args", []string{"arg1", "arg2"})
+	require.Equal(t, `   0.000       message              args=["arg1","arg2"]`+"\n", buf.String())
+}
+
+func TestNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	buf := &bytes.Buffer{}
+	h := console.New(buf)
+	lg := log.New(&log.Config{Level: "info"})
+	lg.SetHandler(h)
+	lg.Warn("message", "status", 500)
+	require.NotContains(t, buf.String(), "\033[")
+	require.Contains(t, buf.String(), "message")
+
+	// colors may still be forced
+	buf.Reset()
+	h.WithColor(true)
+	lg.Warn("message", "status", 500)
+	require.Contains(t, buf.String(), "\033[")
+
+	t.Setenv("NO_COLOR", "")
+	buf.Reset()
+	lg.SetHandler(console.New(buf))
+	lg.Warn("message")
+	require.Contains(t, buf.String(), "\033[")
+}
@@ -0,0 +1,46 @@
+package console_test
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/console"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestOffsetAcrossRecreation(t *testing.T) {
+	falseVal := false
+	now := utc.Now().Add(10 * time.Second)
+
+	offset := func() int64 { // in milliseconds
+		defer utc.MockNow(now)()
+		lg := log.New(&log.Config{
+			Level:       "info",
+			Handler:     "console",
+			GoRoutineID: &falseVal,
+		})
+		buf := &bytes.Buffer{}
+		lg.Handler().(*console.Handler).WithColor(false).Writer = buf
+		lg.Info("message")
+
+		fields := strings.Fields(buf.String())
+		require.NotEmpty(t, fields)
+		res, err := strconv.ParseInt(strings.Replace(fields[0], ".", "", 1), 10, 64)
+		require.NoError(t, err)
+		return res
+	}
+
+	first := offset()
+	require.GreaterOrEqual(t, first, int64(10_000))
+
+	// the re-created handler continues with the same baseline
+	now = now.Add(1500 * time.Millisecond)
+	second := offset()
+	require.Equal(t, first+1500, second)
+}
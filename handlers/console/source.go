@@ -0,0 +1,154 @@
+package console
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithSourceContext enables printing the given number of source lines before
+// and after each stack frame of logged errors, e.g. the stacktraces of
+// eluv-io/errors-go errors. Source files are looked up relative to the given
+// root directories (default: the current working directory), trying the
+// longest matching suffix of the frame's file path first. Frames whose source
+// file cannot be found are printed as is. A value <= 0 disables source context
+// (the default).
+//
+// This option reads source files while logging and is meant for local
+// development only.
+func (h *Handler) WithSourceContext(lines int, roots ...string) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lines <= 0 {
+		h.source = nil
+		return h
+	}
+	if len(roots) == 0 {
+		if wd, err := os.Getwd(); err == nil {
+			roots = []string{wd}
+		}
+	}
+	h.source = &sourceContext{
+		lines: lines,
+		roots: roots,
+	}
+	return h
+}
+
+// frameRegex matches a stack frame line "<tab>path/file.go:line ..." as printed
+// by errors-go.
+var frameRegex = regexp.MustCompile(`^\t(\S+\.go):(\d+)\b`)
+
+// sourceContext adds source lines to the stack frames in error texts.
+type sourceContext struct {
+	lines int
+	roots []string
+	files sync.Map // the lines of source files by frame path - nil if not found
+}
+
+// apply returns the given text with source lines added after each stack frame.
+func (s *sourceContext) apply(text string) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+	sb := strings.Builder{}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(line)
+
+		m := frameRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		src := s.file(m[1])
+		num, err := strconv.Atoi(m[2])
+		if src == nil || err != nil || num < 1 || num > len(src) {
+			continue
+		}
+		from, to := num-s.lines, num+s.lines
+		if from < 1 {
+			from = 1
+		}
+		if to > len(src) {
+			to = len(src)
+		}
+		for n := from; n <= to; n++ {
+			marker := "  "
+			if n == num {
+				marker = "> "
+			}
+			sb.WriteString("\n\t\t")
+			sb.WriteString(marker)
+			sb.WriteString(strconv.Itoa(n))
+			sb.WriteString(" | ")
+			sb.WriteString(src[n-1])
+		}
+	}
+	return sb.String()
+}
+
+// file returns the lines of the source file of the given frame path or nil if
+// the file is not found.
+func (s *sourceContext) file(path string) []string {
+	if src, ok := s.files.Load(path); ok {
+		return src.([]string)
+	}
+	src := readLines(s.resolve(path))
+	s.files.Store(path, src)
+	return src
+}
+
+// resolve returns the location of the source file of the given frame path or
+// an empty string if not found.
+func (s *sourceContext) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		if isFile(path) {
+			return path
+		}
+		return ""
+	}
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	for i := range segments {
+		for _, root := range s.roots {
+			candidate := filepath.Join(root, filepath.Join(segments[i:]...))
+			if isFile(candidate) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
+// readLines returns the lines of the given file or nil if it cannot be read.
+func readLines(path string) []string {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if scanner.Err() != nil {
+		return nil
+	}
+	return lines
+}
@@ -0,0 +1,43 @@
+package console_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/console"
+)
+
+func TestSourceContext(t *testing.T) {
+	falseVal := false
+	lg := log.New(&log.Config{
+		Level:       "info",
+		Handler:     "console",
+		GoRoutineID: &falseVal,
+	})
+	buf := &bytes.Buffer{}
+	h := lg.Handler().(*console.Handler).WithColor(false).WithSourceContext(1)
+	h.Writer = buf
+
+	// line before the error
+	err := errors.E("op", errors.K.Invalid) // the error's frame
+	// line after the error
+	lg.Warn("failed", err)
+
+	out := buf.String()
+	frame := strings.Index(out, "source_test.go:")
+	require.True(t, frame > 0, out)
+	snippet := out[frame:]
+	require.Regexp(t, `\t\t  \d+ \| \t// line before the error\n`, snippet)
+	require.Regexp(t, `\t\t> \d+ \| \terr := errors.E\("op", errors.K.Invalid\) // the error's frame\n`, snippet)
+	require.Regexp(t, `\t\t  \d+ \| \t// line after the error\n`, snippet)
+
+	buf.Reset()
+	h.WithSourceContext(0)
+	lg.Warn("failed", err)
+	require.NotContains(t, buf.String(), "the error's frame")
+}
@@ -0,0 +1,122 @@
+// Package dedup implements a handler wrapper collapsing identical consecutive
+// entries into a single entry with a 'repeated' field holding the number of
+// occurrences.
+//
+// Entries are identical if they have the same level, message and fields. Note
+// that fields like the goroutine ID or the caller may prevent collapsing.
+//
+// Since the number of occurrences is only known once a different entry arrives,
+// each entry is held back until then, or until the flush interval elapses,
+// whichever comes first.
+package dedup
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eluv-io/apexlog-go"
+)
+
+// DefaultFlushInterval is the default interval after which a held back entry
+// is flushed.
+const DefaultFlushInterval = time.Second
+
+// Handler implementation.
+type Handler struct {
+	mu       sync.Mutex
+	handler  log.Handler
+	interval time.Duration
+	pending  *log.Entry  // the held back entry - nil if none
+	key      string      // the key of the pending entry
+	count    int         // the number of occurrences of the pending entry
+	timer    *time.Timer // the timer flushing the pending entry
+}
+
+// New creates a new dedup handler wrapping the given handler.
+func New(h log.Handler) *Handler {
+	return &Handler{
+		handler:  h,
+		interval: DefaultFlushInterval,
+	}
+}
+
+// WithFlushInterval sets the interval after which a held back entry is flushed
+// even if no different entry arrives. Default: DefaultFlushInterval
+func (h *Handler) WithFlushInterval(d time.Duration) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.interval = d
+	return h
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	key := entryKey(e)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pending != nil && key == h.key {
+		h.count++
+		return nil
+	}
+
+	err := h.flushLocked()
+	h.pending = e
+	h.key = key
+	h.count = 1
+	h.timer = time.AfterFunc(h.interval, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.pending == e {
+			_ = h.flushLocked()
+		}
+	})
+	return err
+}
+
+// Asynchronous returns true since entries are held back and may therefore not
+// be pooled.
+func (h *Handler) Asynchronous() bool {
+	return true
+}
+
+// Flush passes the held back entry - if any - to the wrapped handler.
+func (h *Handler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.flushLocked()
+}
+
+func (h *Handler) flushLocked() error {
+	if h.pending == nil {
+		return nil
+	}
+	e := h.pending
+	if h.count > 1 {
+		fields := make(log.Fields, 0, len(e.Fields)+1)
+		fields = append(fields, e.Fields...)
+		fields = append(fields, &log.Field{Name: "repeated", Value: h.count})
+		e.Fields = fields
+	}
+	h.pending = nil
+	h.key = ""
+	h.count = 0
+	h.timer.Stop()
+	return h.handler.HandleLog(e)
+}
+
+// entryKey returns the key identifying identical entries.
+func entryKey(e *log.Entry) string {
+	sb := strings.Builder{}
+	sb.WriteString(e.Level.String())
+	sb.WriteByte(' ')
+	sb.WriteString(e.Message)
+	for _, field := range e.Fields {
+		_, _ = fmt.Fprintf(&sb, " %s=%v", field.Name, field.Value)
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package dedup_test
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/dedup"
+)
+
+func TestHandler(t *testing.T) {
+	inner := &collector{}
+	h := dedup.New(inner).WithFlushInterval(time.Hour)
+	lg := &apex.Logger{Handler: h, Level: apex.TraceLevel}
+
+	lg.Warn("connection failed", "attempt", "n/a", "error", io.EOF)
+	lg.Warn("connection failed", "attempt", "n/a", "error", io.EOF)
+	lg.Warn("connection failed", "attempt", "n/a", "error", io.EOF)
+	require.Equal(t, 0, len(inner.get()))
+
+	lg.Info("connected")
+	entries := inner.get()
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, "connection failed", entries[0].Message)
+	require.Equal(t, "n/a", entries[0].Fields.Get("attempt"))
+	require.Equal(t, 3, entries[0].Fields.Get("repeated"))
+
+	require.NoError(t, h.Flush())
+	entries = inner.get()
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, "connected", entries[1].Message)
+	require.Nil(t, entries[1].Fields.Get("repeated"))
+
+	// same message, different fields or level
+	lg.Info("connected", "host", "a")
+	lg.Info("connected", "host", "b")
+	lg.Warn("connected", "host", "b")
+	require.NoError(t, h.Flush())
+	require.Equal(t, 5, len(inner.get()))
+}
+
+func TestFlushInterval(t *testing.T) {
+	inner := &collector{}
+	h := dedup.New(inner).WithFlushInterval(10 * time.Millisecond)
+	lg := &apex.Logger{Handler: h, Level: apex.TraceLevel}
+
+	lg.Info("retrying")
+	lg.Info("retrying")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(inner.get()) == 0 {
+		require.True(t, time.Now().Before(deadline), "pending entry not flushed")
+		time.Sleep(5 * time.Millisecond)
+	}
+	entries := inner.get()
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, 2, entries[0].Fields.Get("repeated"))
+}
+
+type collector struct {
+	mu      sync.Mutex
+	entries []*apex.Entry
+}
+
+func (c *collector) HandleLog(e *apex.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, e)
+	return nil
+}
+
+func (c *collector) get() []*apex.Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*apex.Entry(nil), c.entries...)
+}
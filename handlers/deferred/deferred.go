@@ -0,0 +1,119 @@
+// Package deferred implements a handler wrapper for a "quiet until error" mode:
+// entries below a trigger level are held back in a ring buffer and only passed
+// to the wrapped handler once an entry at or above the trigger level arrives,
+// providing the context that led to it. Held back entries are discarded when
+// the buffer is full (oldest first) or when they exceed the maximum age.
+//
+// Note that the level of the logger still applies: entries must be enabled in
+// order to be buffered.
+package deferred
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// DefaultBufferSize is the default maximum number of held back entries.
+const DefaultBufferSize = 100
+
+// DefaultMaxAge is the default maximum age of held back entries.
+const DefaultMaxAge = time.Minute
+
+// Handler implementation.
+type Handler struct {
+	mu      sync.Mutex
+	handler log.Handler
+	trigger log.Level
+	maxAge  time.Duration
+	buf     []*bufferedEntry // the ring buffer
+	start   int              // the index of the oldest entry in the ring buffer
+	count   int              // the number of entries in the ring buffer
+}
+
+type bufferedEntry struct {
+	entry *log.Entry
+	added utc.UTC
+}
+
+// New creates a new deferred handler wrapping the given handler, which holds
+// back entries below the given trigger level.
+func New(h log.Handler, trigger log.Level) *Handler {
+	return &Handler{
+		handler: h,
+		trigger: trigger,
+		maxAge:  DefaultMaxAge,
+		buf:     make([]*bufferedEntry, DefaultBufferSize),
+	}
+}
+
+// WithBufferSize sets the maximum number of held back entries. When the buffer
+// is full, the oldest entry is discarded. A size <= 0 is ignored. Changing the
+// size discards all held back entries. Default: DefaultBufferSize
+func (h *Handler) WithBufferSize(size int) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if size > 0 {
+		h.buf = make([]*bufferedEntry, size)
+		h.start = 0
+		h.count = 0
+	}
+	return h
+}
+
+// WithMaxAge sets the maximum age of held back entries. Older entries are
+// discarded instead of being flushed. A value <= 0 disables the age limit.
+// Default: DefaultMaxAge
+func (h *Handler) WithMaxAge(d time.Duration) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxAge = d
+	return h
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := utc.Now()
+	if e.Level < h.trigger {
+		idx := (h.start + h.count) % len(h.buf)
+		if h.count == len(h.buf) {
+			// discard the oldest entry
+			h.start = (h.start + 1) % len(h.buf)
+		} else {
+			h.count++
+		}
+		h.buf[idx] = &bufferedEntry{entry: e, added: now}
+		return nil
+	}
+
+	var err error
+	for i := 0; i < h.count; i++ {
+		idx := (h.start + i) % len(h.buf)
+		be := h.buf[idx]
+		h.buf[idx] = nil
+		if h.maxAge > 0 && now.Sub(be.added) > h.maxAge {
+			continue
+		}
+		if herr := h.handler.HandleLog(be.entry); err == nil {
+			err = herr
+		}
+	}
+	h.start = 0
+	h.count = 0
+
+	if herr := h.handler.HandleLog(e); err == nil {
+		err = herr
+	}
+	return err
+}
+
+// Asynchronous returns true since entries are held back and may therefore not
+// be pooled.
+func (h *Handler) Asynchronous() bool {
+	return true
+}
@@ -0,0 +1,96 @@
+package deferred_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/deferred"
+	"github.com/eluv-io/utc-go"
+)
+
+func messages(h *memory.Handler) []string {
+	var ret []string
+	for _, e := range h.Entries {
+		ret = append(ret, e.Message)
+	}
+	return ret
+}
+
+func TestHandler(t *testing.T) {
+	inner := memory.New()
+	h := deferred.New(inner, apex.ErrorLevel).WithBufferSize(3)
+	lg := &apex.Logger{Handler: h, Level: apex.TraceLevel}
+
+	lg.Debug("debug 1")
+	lg.Info("info 1")
+	lg.Warn("warn 1")
+	require.Empty(t, inner.Entries)
+
+	lg.Error("error 1")
+	require.Equal(t, []string{"debug 1", "info 1", "warn 1", "error 1"}, messages(inner))
+
+	// the buffer is emptied by the flush and discards the oldest entries
+	inner.Entries = nil
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		lg.Debug(msg)
+	}
+	lg.Error("error 2")
+	require.Equal(t, []string{"c", "d", "e", "error 2"}, messages(inner))
+}
+
+func TestMaxAge(t *testing.T) {
+	now := utc.UnixMilli(0)
+	defer utc.MockNowFn(func() utc.UTC { return now })()
+
+	inner := memory.New()
+	h := deferred.New(inner, apex.WarnLevel).WithMaxAge(time.Minute)
+	lg := &apex.Logger{Handler: h, Level: apex.TraceLevel}
+
+	lg.Debug("old")
+	now = now.Add(50 * time.Second)
+	lg.Debug("recent")
+	now = now.Add(20 * time.Second)
+	lg.Warn("warning")
+	require.Equal(t, []string{"recent", "warning"}, messages(inner))
+}
+
+func TestConfig(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "deferred.log")
+	lg := log.New(&log.Config{
+		Level:      "debug",
+		Handler:    "text",
+		File:       &log.LumberjackConfig{Filename: fname},
+		DeferUntil: "error",
+	})
+	_, ok := lg.Handler().(*deferred.Handler)
+	require.True(t, ok)
+
+	read := func() string {
+		bb, err := os.ReadFile(fname)
+		if os.IsNotExist(err) {
+			return ""
+		}
+		require.NoError(t, err)
+		return string(bb)
+	}
+
+	lg.Debug("loading")
+	lg.Info("processing")
+	require.Empty(t, read())
+
+	lg.Error("failed")
+	out := read()
+	require.Contains(t, out, "loading")
+	require.Contains(t, out, "processing")
+	require.Contains(t, out, "failed")
+	require.Less(t, strings.Index(out, "loading"), strings.Index(out, "failed"))
+	require.NoError(t, lg.Close())
+}
@@ -0,0 +1,128 @@
+// Package gcp implements a json handler producing the structured log format of
+// Google Cloud Logging: the entry's 'severity', 'message' and 'timestamp'
+// (RFC 3339 with nanoseconds), the caller as source location and the fields as
+// labels, with values converted to strings.
+//
+// See https://cloud.google.com/logging/docs/structured-logging
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+	"github.com/eluv-io/log-go/handlers/internal/values"
+)
+
+// Default handler outputting to stderr.
+var Default = New(os.Stderr)
+
+// Severities maps log levels to Cloud Logging severities.
+var Severities = [...]string{
+	log.TraceLevel: "DEBUG",
+	log.DebugLevel: "DEBUG",
+	log.InfoLevel:  "INFO",
+	log.WarnLevel:  "WARNING",
+	log.ErrorLevel: "ERROR",
+	log.FatalLevel: "CRITICAL",
+}
+
+// Handler implementation.
+type Handler struct {
+	mu     sync.Mutex
+	Writer io.Writer
+}
+
+// New creates a new gcp handler.
+func New(w io.Writer) *Handler {
+	return &Handler{
+		Writer: w,
+	}
+}
+
+// entry is the Cloud Logging representation of a log entry.
+type entry struct {
+	Severity       string            `json:"severity"`
+	Message        string            `json:"message"`
+	Timestamp      string            `json:"timestamp"`
+	SourceLocation *sourceLocation   `json:"logging.googleapis.com/sourceLocation,omitempty"`
+	Labels         map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+}
+
+type sourceLocation struct {
+	File string `json:"file"`
+	Line string `json:"line,omitempty"`
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	ent := &entry{
+		Severity:  Severities[e.Level],
+		Message:   e.Message,
+		Timestamp: timestamp.Of(e).Format(time.RFC3339Nano),
+	}
+
+	// the caller field (if enabled) is converted to the source location, all
+	// other fields to labels
+	for _, field := range e.Fields {
+		if field.Name == "caller" {
+			switch v := field.Value.(type) {
+			case string:
+				ent.SourceLocation = newSourceLocation(v)
+				continue
+			case fmt.Stringer:
+				ent.SourceLocation = newSourceLocation(v.String())
+				continue
+			}
+		}
+		if ent.Labels == nil {
+			ent.Labels = make(map[string]string, len(e.Fields))
+		}
+		ent.Labels[field.Name] = labelValue(field.Value)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(ent)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err = h.Writer.Write(buf.Bytes())
+	return err
+}
+
+// labelValue returns the string representation of the given field value.
+func labelValue(val interface{}) string {
+	switch v := values.Resolve(val).(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// newSourceLocation parses the given caller string formatted as "file:line".
+func newSourceLocation(caller string) *sourceLocation {
+	idx := strings.LastIndex(caller, ":")
+	if idx < 0 {
+		return &sourceLocation{File: caller}
+	}
+	return &sourceLocation{
+		File: caller[:idx],
+		Line: caller[idx+1:],
+	}
+}
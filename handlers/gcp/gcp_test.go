@@ -0,0 +1,79 @@
+package gcp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/gcp"
+	"github.com/eluv-io/log-go/handlers/handlertest"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestHandler(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+	fls := false
+	tru := true
+
+	lg := log.New(&log.Config{
+		Level:       "trace",
+		Handler:     "gcp",
+		GoRoutineID: &fls,
+		Caller:      &tru,
+	})
+	handler, ok := lg.Handler().(*gcp.Handler)
+	require.True(t, ok)
+	buf := &bytes.Buffer{}
+	handler.Writer = buf
+
+	lg.Trace("trace message", "field1", "value1")
+	lg.Debug("debug message", "field1", "value1")
+	lg.Info("info message", "field1", "value1")
+	lg.Warn("warn message", "field1", "value1")
+	lg.Error("error message", "field1", "value1")
+
+	wantSeverities := []string{"DEBUG", "DEBUG", "INFO", "WARNING", "ERROR"}
+	wantMessages := []string{"trace message", "debug message", "info message", "warn message", "error message"}
+
+	sc := bufio.NewScanner(buf)
+	count := 0
+	for ; sc.Scan(); count++ {
+		m := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &m))
+
+		require.Equal(t, wantSeverities[count], m["severity"])
+		require.Equal(t, wantMessages[count], m["message"])
+		require.Equal(t, "1970-01-01T00:00:00Z", m["timestamp"])
+		require.Equal(t, map[string]interface{}{"logger": "/", "field1": "value1"}, m["logging.googleapis.com/labels"])
+
+		loc, ok := m["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, "gcp_test.go", loc["file"])
+		require.NotEmpty(t, loc["line"])
+	}
+	require.Equal(t, 5, count)
+}
+
+func TestLabelsAndTimestamp(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0).Add(123456789 * time.Nanosecond))()
+
+	out := handlertest.Render(gcp.New(io.Discard), apex.FatalLevel, "fatal message",
+		"count", 3, "ok", true, io.EOF)
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(out), &m))
+	require.Equal(t, "CRITICAL", m["severity"])
+	require.Equal(t, "1970-01-01T00:00:00.123456789Z", m["timestamp"])
+	require.Equal(t, map[string]interface{}{"count": "3", "ok": "true", "error": "EOF"},
+		m["logging.googleapis.com/labels"])
+	require.Nil(t, m["logging.googleapis.com/sourceLocation"])
+
+	out = handlertest.Render(gcp.New(io.Discard), apex.InfoLevel, "no fields")
+	require.NotContains(t, out, "labels")
+}
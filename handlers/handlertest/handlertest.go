@@ -0,0 +1,66 @@
+// Package handlertest provides helpers for testing log handlers in isolation,
+// without constructing a full Log.
+package handlertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// Render logs the given message and fields at the given level with the given
+// handler and returns the rendered output. Fields are parsed the same way as
+// for a Log, i.e. as key-value pairs, errors or field instances.
+//
+// The handler must be a pointer to a struct with an exported 'Writer' field of
+// type io.Writer, like all handlers in this module. The writer is temporarily
+// replaced by a buffer, so Render must not be used concurrently with other
+// uses of the handler. Panics if the handler has no such field.
+func Render(h apex.Handler, level apex.Level, msg string, fields ...interface{}) string {
+	writer := writerField(h)
+	prev := reflect.New(writer.Type()).Elem()
+	prev.Set(writer)
+	defer writer.Set(prev)
+
+	buf := &bytes.Buffer{}
+	writer.Set(reflect.ValueOf(io.Writer(buf)))
+
+	lg := &apex.Logger{
+		Handler: &levelHandler{handler: h, level: level},
+		Level:   apex.TraceLevel,
+	}
+	// log at Trace level, since Fatal would exit - the level is set by the
+	// levelHandler
+	lg.Trace(msg, fields...)
+
+	return buf.String()
+}
+
+// writerField returns the settable 'Writer' field of the given handler.
+func writerField(h apex.Handler) reflect.Value {
+	v := reflect.ValueOf(h)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		f := v.Elem().FieldByName("Writer")
+		if f.IsValid() && f.CanSet() && f.Type() == reflect.TypeOf((*io.Writer)(nil)).Elem() {
+			return f
+		}
+	}
+	panic(fmt.Sprintf("handlertest: handler %T has no Writer field of type io.Writer", h))
+}
+
+// levelHandler sets the level of entries and their timestamp from utc-go's
+// (mockable) clock before passing them on.
+type levelHandler struct {
+	handler apex.Handler
+	level   apex.Level
+}
+
+func (h *levelHandler) HandleLog(e *apex.Entry) error {
+	e.Level = h.level
+	e.Timestamp = utc.Now().Time
+	return h.handler.HandleLog(e)
+}
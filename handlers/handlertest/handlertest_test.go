@@ -0,0 +1,36 @@
+package handlertest_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/handlertest"
+	"github.com/eluv-io/log-go/handlers/text"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestRender(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	h := text.New(io.Discard)
+
+	out := handlertest.Render(h, apex.InfoLevel, "info message", "field1", "value1", "field2", 2)
+	require.Equal(t, "1970-01-01T00:00:00.000Z INFO  info message              field1=value1 field2=2\n", out)
+
+	out = handlertest.Render(h, apex.FatalLevel, "fatal message", io.EOF)
+	require.Equal(t, "1970-01-01T00:00:00.000Z FATAL fatal message             error=EOF\n", out)
+
+	// the handler's writer is restored
+	require.Equal(t, io.Discard, h.Writer)
+
+	require.Panics(t, func() {
+		handlertest.Render(&noWriter{}, apex.InfoLevel, "message")
+	})
+}
+
+type noWriter struct{}
+
+func (*noWriter) HandleLog(*apex.Entry) error { return nil }
@@ -0,0 +1,34 @@
+// Package bufpool provides the pool of buffers used by handlers for rendering
+// log entries.
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// pool is the pool of buffers used for rendering log entries.
+var pool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+// maxPooledBufSize is the capacity above which buffers are not returned to
+// the pool, in order not to retain the memory of exceptionally large entries.
+const maxPooledBufSize = 64 * 1024
+
+// Get returns an empty buffer from the pool.
+func Get() *bytes.Buffer {
+	buf := pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// Put returns the given buffer to the pool unless it exceeds the maximum size
+// of pooled buffers.
+func Put(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufSize {
+		pool.Put(buf)
+	}
+}
@@ -0,0 +1,16 @@
+// Package timestamp provides the timestamp of log entries to handlers.
+package timestamp
+
+import (
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// Of returns the timestamp of the given entry, or the current time if the
+// entry has no timestamp, e.g. when created manually.
+func Of(e *log.Entry) utc.UTC {
+	if e.Timestamp.IsZero() {
+		return utc.Now()
+	}
+	return utc.New(e.Timestamp)
+}
@@ -0,0 +1,71 @@
+// Package values provides helpers for rendering field values in handlers.
+package values
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Resolve returns the resolved value of lazy values like log.LazyValue, which
+// are resolved on first use. Other values are returned unchanged.
+func Resolve(val interface{}) interface{} {
+	if lazy, ok := val.(interface{ Resolve() interface{} }); ok {
+		return lazy.Resolve()
+	}
+	return val
+}
+
+// JSON returns the compact json representation of the given value if it is a
+// complex value (struct, map or a pointer to one of them). Errors and
+// fmt.Stringers are not considered complex values, since their textual
+// representation is usually more meaningful. Returns false if the value is not
+// a complex value or cannot be marshalled.
+func JSON(val interface{}) (string, bool) {
+	switch val.(type) {
+	case nil, error, fmt.Stringer:
+		return "", false
+	}
+
+	t := reflect.TypeOf(val)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+	default:
+		return "", false
+	}
+
+	bb, err := json.Marshal(val)
+	if err != nil {
+		return "", false
+	}
+	return string(bb), true
+}
+
+// JSONArray returns the compact json representation of the given value if it
+// is a slice or an array, e.g. ["arg1","arg2"]. Byte slices are not considered
+// arrays. Returns false if the value is not a slice or an array or cannot be
+// marshalled.
+func JSONArray(val interface{}) (string, bool) {
+	if val == nil {
+		return "", false
+	}
+	t := reflect.TypeOf(val)
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "", false
+		}
+	case reflect.Array:
+	default:
+		return "", false
+	}
+
+	bb, err := json.Marshal(val)
+	if err != nil {
+		return "", false
+	}
+	return string(bb), true
+}
@@ -0,0 +1,223 @@
+// Package json implements a json handler with configurable formatting.
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+)
+
+// Numeric severity schemes, see Handler.WithSeverityScheme.
+const (
+	// SeverityApex uses the numeric value of the log level: trace=0, debug=1,
+	// info=2, warn=3, error=4, fatal=5. Higher is more severe.
+	SeverityApex = "apex"
+	// SeveritySyslog uses the syslog severities of RFC 5424: fatal=2
+	// (critical), error=3, warn=4, info=6, debug=7, trace=7. Lower is more
+	// severe.
+	SeveritySyslog = "syslog"
+)
+
+// syslogSeverities are the syslog severities of the log levels.
+var syslogSeverities = [...]int{
+	log.TraceLevel: 7,
+	log.DebugLevel: 7,
+	log.InfoLevel:  6,
+	log.WarnLevel:  4,
+	log.ErrorLevel: 3,
+	log.FatalLevel: 2,
+}
+
+// Default handler outputting to stderr.
+var Default = New(os.Stderr)
+
+// Handler implementation.
+type Handler struct {
+	mu       sync.Mutex
+	Writer   io.Writer
+	prefix   string
+	indent   string
+	array    bool
+	sorted   bool
+	numeric  bool   // true to add the numeric severity
+	severity string // the numeric severity scheme
+	caller   bool   // true to request the caller as Caller object
+}
+
+// callerFrame is implemented by the caller info of log calls, e.g.
+// log.CallerInfo.
+type callerFrame interface {
+	CallerFrame() (file string, line int, fn string)
+}
+
+// Caller is the json representation of the caller of a log call, see
+// Handler.WithStructuredCaller.
+type Caller struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Func string `json:"func"`
+}
+
+// String returns the caller formatted as "file:line".
+func (c Caller) String() string {
+	return c.File + ":" + strconv.Itoa(c.Line)
+}
+
+// New creates a new json handler.
+func New(w io.Writer) *Handler {
+	return &Handler{
+		Writer: w,
+	}
+}
+
+// WithIndent sets the prefix and indentation used to pretty-print each entry.
+// See json.Encoder.SetIndent for details.
+func (h *Handler) WithIndent(prefix, indent string) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prefix = prefix
+	h.indent = indent
+	return h
+}
+
+// WithFieldArray encodes the fields as an array of name/value objects instead
+// of a json object if use is true. This preserves the order of the fields as
+// logged also for consumers that parse json objects into unordered maps:
+//
+//	"fields":[{"name":"a","value":1},{"name":"b","value":2}]
+func (h *Handler) WithFieldArray(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.array = use
+	return h
+}
+
+// WithSortedFields sorts the fields by name if use is true, producing stable,
+// diff-friendly output regardless of the order in which the fields were logged.
+func (h *Handler) WithSortedFields(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sorted = use
+	return h
+}
+
+// WithNumericSeverity adds the level as numeric 'severity' alongside the
+// string 'level' if use is true, for systems that sort by numeric severity. The
+// scheme is selected with WithSeverityScheme.
+func (h *Handler) WithNumericSeverity(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.numeric = use
+	return h
+}
+
+// WithSeverityScheme sets the numeric severity scheme: SeverityApex or
+// SeveritySyslog. Unknown schemes are treated as SeverityApex.
+// Default: SeverityApex
+func (h *Handler) WithSeverityScheme(scheme string) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.severity = scheme
+	return h
+}
+
+// WithStructuredCaller renders the caller info of loggers configured with
+// Caller as nested object instead of a "file:line" string if use is true:
+//
+//	"caller":{"file":"main.go","line":42,"func":"main.main"}
+func (h *Handler) WithStructuredCaller(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.caller = use
+	return h
+}
+
+// severityOf returns the numeric severity of the given level according to the
+// configured scheme.
+func (h *Handler) severityOf(level log.Level) int {
+	if h.severity == SeveritySyslog && level >= 0 && int(level) < len(syslogSeverities) {
+		return syslogSeverities[level]
+	}
+	return int(level)
+}
+
+// entry is the json representation of a log entry.
+type entry struct {
+	Fields    interface{} `json:"fields"`
+	Level     log.Level   `json:"level"`
+	Severity  *int        `json:"severity,omitempty"`
+	Timestamp string      `json:"timestamp"`
+	Message   string      `json:"message"`
+}
+
+// field is the json representation of a field in field array mode.
+type field struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(h.prefix, h.indent)
+
+	ef := e.Fields
+	if h.sorted || h.caller {
+		ef = make(log.Fields, len(e.Fields))
+		copy(ef, e.Fields)
+	}
+	if h.caller {
+		for i, f := range ef {
+			if cf, ok := f.Value.(callerFrame); ok {
+				file, line, fn := cf.CallerFrame()
+				ef[i] = &log.Field{Name: f.Name, Value: Caller{File: file, Line: line, Func: fn}}
+			}
+		}
+	}
+	if h.sorted {
+		sort.SliceStable(ef, func(i, j int) bool {
+			return ef[i].Name < ef[j].Name
+		})
+	}
+
+	var fields interface{} = ef
+	if h.array {
+		arr := make([]field, len(ef))
+		for i, f := range ef {
+			arr[i] = field{Name: f.Name, Value: f.Value}
+		}
+		fields = arr
+	}
+
+	var severity *int
+	if h.numeric {
+		s := h.severityOf(e.Level)
+		severity = &s
+	}
+
+	err := enc.Encode(&entry{
+		Fields:    fields,
+		Level:     e.Level,
+		Severity:  severity,
+		Timestamp: timestamp.Of(e).String(),
+		Message:   e.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = h.Writer.Write(buf.Bytes())
+	return err
+}
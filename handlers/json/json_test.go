@@ -0,0 +1,172 @@
+package json_test
+
+import (
+	"bytes"
+	ejson "encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/deferred"
+	"github.com/eluv-io/log-go/handlers/handlertest"
+	"github.com/eluv-io/log-go/handlers/json"
+	"github.com/eluv-io/utc-go"
+)
+
+func ExampleHandler() {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	fls := false
+	lg := log.New(&log.Config{
+		Level:       "trace",
+		Handler:     "json-pretty",
+		GoRoutineID: &fls,
+	})
+
+	lg.Info("info message", "field1", "value1", "field2", 2)
+	lg.Warn("warn message", "field1", "value1", "field2", []string{"a", "b"})
+
+	// Output:
+	// {
+	//   "fields": {
+	//     "logger": "/",
+	//     "field1": "value1",
+	//     "field2": 2
+	//   },
+	//   "level": "info",
+	//   "timestamp": "1970-01-01T00:00:00.000Z",
+	//   "message": "info message"
+	// }
+	// {
+	//   "fields": {
+	//     "logger": "/",
+	//     "field1": "value1",
+	//     "field2": [
+	//       "a",
+	//       "b"
+	//     ]
+	//   },
+	//   "level": "warn",
+	//   "timestamp": "1970-01-01T00:00:00.000Z",
+	//   "message": "warn message"
+	// }
+}
+
+func TestFieldOrder(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	tests := []struct {
+		array bool
+		want  string
+	}{
+		{
+			array: false,
+			want:  `{"fields":{"c":3,"a":1,"b":2},"level":"info","timestamp":"1970-01-01T00:00:00.000Z","message":"msg"}` + "\n",
+		},
+		{
+			array: true,
+			want:  `{"fields":[{"name":"c","value":3},{"name":"a","value":1},{"name":"b","value":2}],"level":"info","timestamp":"1970-01-01T00:00:00.000Z","message":"msg"}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		lg := &apex.Logger{
+			Handler: json.New(buf).WithFieldArray(test.array),
+			Level:   apex.InfoLevel,
+		}
+		lg.Info("msg", "c", 3, "a", 1, "b", 2)
+		require.Equal(t, test.want, buf.String())
+	}
+}
+
+func TestSortedFields(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	buf := &bytes.Buffer{}
+	lg := &apex.Logger{
+		Handler: json.New(buf).WithSortedFields(true),
+		Level:   apex.InfoLevel,
+	}
+	lg.Info("msg", "c", 3, "a", 1, "b", 2)
+	lg.Info("msg", "b", 2, "c", 3, "a", 1)
+
+	want := `{"fields":{"a":1,"b":2,"c":3},"level":"info","timestamp":"1970-01-01T00:00:00.000Z","message":"msg"}` + "\n"
+	require.Equal(t, want+want, buf.String())
+}
+
+func TestNumericSeverity(t *testing.T) {
+	levels := []apex.Level{apex.TraceLevel, apex.DebugLevel, apex.InfoLevel, apex.WarnLevel, apex.ErrorLevel, apex.FatalLevel}
+	tests := []struct {
+		scheme string
+		want   []int
+	}{
+		{json.SeverityApex, []int{0, 1, 2, 3, 4, 5}},
+		{json.SeveritySyslog, []int{7, 7, 6, 4, 3, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.scheme, func(t *testing.T) {
+			h := json.New(io.Discard).WithNumericSeverity(true).WithSeverityScheme(test.scheme)
+			for i, level := range levels {
+				out := handlertest.Render(h, level, "msg")
+				require.Contains(t, out, fmt.Sprintf(`"level":"%s","severity":%d,`, level, test.want[i]))
+			}
+		})
+	}
+
+	// disabled by default
+	out := handlertest.Render(json.New(io.Discard), apex.InfoLevel, "msg")
+	require.NotContains(t, out, "severity")
+}
+
+func TestStructuredCaller(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "json-sorted", Caller: &trueVal})
+	h := lg.Handler().(*json.Handler)
+	buf := &bytes.Buffer{}
+	h.Writer = buf
+
+	type entry struct {
+		Fields struct {
+			Caller interface{} `json:"caller"`
+		} `json:"fields"`
+	}
+	parse := func() interface{} {
+		var e entry
+		require.NoError(t, ejson.Unmarshal(buf.Bytes(), &e))
+		buf.Reset()
+		return e.Fields.Caller
+	}
+
+	lg.Info("message")
+	_, _, line, _ := runtime.Caller(0)
+	require.Equal(t, fmt.Sprintf("json_test.go:%d", line-1), parse())
+
+	h.WithStructuredCaller(true)
+	lg.Info("message")
+	_, _, line, _ = runtime.Caller(0)
+	want := map[string]interface{}{
+		"file": "json_test.go",
+		"line": float64(line - 1),
+		"func": "github.com/eluv-io/log-go/handlers/json_test.TestStructuredCaller",
+	}
+	require.Equal(t, want, parse())
+
+	// also when wrapped by another handler
+	lg.SetHandler(deferred.New(h, apex.InfoLevel))
+	lg.Info("message")
+	_, _, line, _ = runtime.Caller(0)
+	want["line"] = float64(line - 1)
+	require.Equal(t, want, parse())
+
+	// the entry itself is left untouched for other handlers
+	caller := log.CallerInfo{File: "file.go", Line: 1, Func: "fn"}
+	e := &apex.Entry{Level: apex.InfoLevel, Message: "message", Fields: apex.Fields{{Name: "caller", Value: caller}}}
+	require.NoError(t, h.HandleLog(e))
+	require.Equal(t, caller, e.Fields[0].Value)
+	require.Equal(t, map[string]interface{}{"file": "file.go", "line": float64(1), "func": "fn"}, parse())
+}
@@ -1,59 +1,166 @@
 // Package raw is like handlers/text, but omits the "log level" field and prints
-// the "raw" field without label on a separate line.
+// the "raw" field without label on a separate line. The name of the raw field
+// is configurable, see Handler.WithRawField.
 package raw
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 	"sync"
 
 	"github.com/eluv-io/apexlog-go"
-	"github.com/eluv-io/utc-go"
+	"github.com/eluv-io/log-go/handlers/internal/bufpool"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+	"github.com/eluv-io/log-go/handlers/internal/values"
 )
 
+// DefaultRawField is the default name of the field printed on a separate line.
+const DefaultRawField = "raw"
+
 // Default handler outputting to stderr.
 var Default = New(os.Stderr)
 
 // Handler implementation.
 type Handler struct {
-	mu     sync.Mutex
-	Writer io.Writer
+	mu       sync.Mutex
+	Writer   io.Writer
+	writers  []*addedWriter // additional writers
+	jsonMeta bool
+	rawField string // the name of the field printed on a separate line
+}
+
+type addedWriter struct {
+	io.Writer
 }
 
 // New creates a new raw handler.
 func New(w io.Writer) *Handler {
 	return &Handler{
-		Writer: w,
+		Writer:   w,
+		rawField: DefaultRawField,
+	}
+}
+
+// AddWriter adds a writer that receives all output in addition to the
+// handler's Writer. Returns a function that removes the writer again.
+func (h *Handler) AddWriter(w io.Writer) (remove func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	aw := &addedWriter{Writer: w}
+	h.writers = append(h.writers, aw)
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		for i, writer := range h.writers {
+			if writer == aw {
+				h.writers = append(h.writers[:i:i], h.writers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// WithJSONMeta renders the first line of an entry as a single json object
+// instead of space-separated key=value pairs if use is true, making the
+// metadata of the raw payload machine-parseable. The raw and "logger" fields
+// are omitted from the object:
+//
+//	{"timestamp":"...","message":"request","fields":{"method":"GET"}}
+//	<raw payload>
+func (h *Handler) WithJSONMeta(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.jsonMeta = use
+	return h
+}
+
+// WithRawField sets the name of the field that is printed without label on a
+// separate line, e.g. "body" or "payload". An empty name restores the default.
+// Default: DefaultRawField
+func (h *Handler) WithRawField(name string) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if name == "" {
+		name = DefaultRawField
 	}
+	h.rawField = name
+	return h
+}
+
+// meta is the json representation of the first line of an entry in json meta
+// mode.
+type meta struct {
+	Timestamp string     `json:"timestamp"`
+	Message   string     `json:"message"`
+	Fields    log.Fields `json:"fields"`
 }
 
 // HandleLog implements log.Handler.
 func (h *Handler) HandleLog(e *log.Entry) error {
-	sb := &strings.Builder{}
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+
+	h.mu.Lock()
+	useJSONMeta, rawField := h.jsonMeta, h.rawField
+	h.mu.Unlock()
 
-	_, _ = fmt.Fprintf(sb, "%s %-25s", utc.Now().String(), e.Message)
+	if !useJSONMeta || !jsonMetaLine(buf, e, rawField) {
+		_, _ = fmt.Fprintf(buf, "%s %-25s", timestamp.Of(e).String(), e.Message)
 
-	for _, field := range e.Fields {
-		switch field.Name {
-		case "raw":
-		case "logger":
-		default:
-			_, _ = fmt.Fprintf(sb, " %s=%v", field.Name, field.Value)
+		for _, field := range e.Fields {
+			switch field.Name {
+			case rawField:
+			case "logger":
+			default:
+				_, _ = fmt.Fprintf(buf, " %s=%v", field.Name, field.Value)
+			}
 		}
 	}
 
-	sb.Write([]byte{'\n'})
-	raw := e.Fields.Get("raw")
+	buf.Write([]byte{'\n'})
+	raw := values.Resolve(e.Fields.Get(rawField))
 	if raw != "" && raw != nil {
-		_, _ = fmt.Fprintf(sb, "%v\n\n", raw)
+		_, _ = fmt.Fprintf(buf, "%v\n\n", raw)
 	}
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	_, _ = h.Writer.Write([]byte(sb.String()))
+	bb := buf.Bytes()
+	_, _ = h.Writer.Write(bb)
+	for _, writer := range h.writers {
+		_, _ = writer.Write(bb)
+	}
 
 	return nil
 }
+
+// jsonMetaLine writes the first line of the given entry as json object, omitting
+// the given raw field. Returns false if the fields cannot be marshalled.
+func jsonMetaLine(buf *bytes.Buffer, e *log.Entry, rawField string) bool {
+	m := &meta{
+		Timestamp: timestamp.Of(e).String(),
+		Message:   e.Message,
+		Fields:    make(log.Fields, 0, len(e.Fields)),
+	}
+	for _, field := range e.Fields {
+		switch field.Name {
+		case rawField, "logger":
+		default:
+			m.Fields = append(m.Fields, field)
+		}
+	}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return false
+	}
+	buf.Truncate(buf.Len() - 1) // remove the newline added by the encoder
+	return true
+}
@@ -1,9 +1,16 @@
 package raw_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
 
 	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/raw"
 	"github.com/eluv-io/utc-go"
 )
 
@@ -54,3 +61,97 @@ func ExampleHandler() {
 	// raw string
 	//
 }
+
+func TestAddWriter(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	fls := false
+	lg := log.New(&log.Config{
+		Level:       "debug",
+		Handler:     "raw",
+		GoRoutineID: &fls,
+	})
+	handler, ok := lg.Handler().(*raw.Handler)
+	require.True(t, ok)
+
+	primary := &bytes.Buffer{}
+	handler.Writer = primary
+	added := &bytes.Buffer{}
+	remove := handler.AddWriter(added)
+
+	lg.Info("request", "method", "GET", "raw", "GET / HTTP/1.1")
+	want := "" +
+		"1970-01-01T00:00:00.000Z request                   method=GET\n" +
+		"GET / HTTP/1.1\n\n"
+	require.Equal(t, want, primary.String())
+	require.Equal(t, want, added.String())
+
+	remove()
+	remove() // idempotent
+
+	lg.Info("request", "method", "PUT", "raw", "PUT / HTTP/1.1")
+	require.Contains(t, primary.String(), "PUT / HTTP/1.1")
+	require.Equal(t, want, added.String())
+}
+
+func TestJSONMeta(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	fls := false
+	lg := log.New(&log.Config{
+		Level:       "debug",
+		Handler:     "raw",
+		GoRoutineID: &fls,
+	})
+	handler := lg.Handler().(*raw.Handler).WithJSONMeta(true)
+	buf := &bytes.Buffer{}
+	handler.Writer = buf
+
+	body := "GET /a?b=<c>&d HTTP/1.1\nHost: localhost"
+	lg.Info("request", "method", "GET", "status", 200, "raw", body)
+
+	first, rest, found := strings.Cut(buf.String(), "\n")
+	require.True(t, found)
+	var meta struct {
+		Timestamp string                 `json:"timestamp"`
+		Message   string                 `json:"message"`
+		Fields    map[string]interface{} `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(first), &meta))
+	require.Equal(t, "1970-01-01T00:00:00.000Z", meta.Timestamp)
+	require.Equal(t, "request", meta.Message)
+	require.Equal(t, map[string]interface{}{"method": "GET", "status": float64(200)}, meta.Fields)
+	require.Equal(t, body+"\n\n", rest)
+
+	buf.Reset()
+	handler.WithJSONMeta(false)
+	lg.Info("request", "method", "GET")
+	require.Equal(t, "1970-01-01T00:00:00.000Z request                   method=GET\n", buf.String())
+}
+
+func TestRawField(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	fls := false
+	lg := log.New(&log.Config{
+		Level:       "debug",
+		Handler:     "raw",
+		GoRoutineID: &fls,
+		RawField:    "body",
+	})
+	handler := lg.Handler().(*raw.Handler)
+	buf := &bytes.Buffer{}
+	handler.Writer = buf
+
+	lg.Info("request", "method", "GET", "body", "GET / HTTP/1.1", "raw", "not special")
+	require.Equal(t, ""+
+		"1970-01-01T00:00:00.000Z request                   method=GET raw=not special\n"+
+		"GET / HTTP/1.1\n\n", buf.String())
+
+	buf.Reset()
+	handler.WithRawField("")
+	lg.Info("request", "body", "b", "raw", "r")
+	require.Equal(t, ""+
+		"1970-01-01T00:00:00.000Z request                   body=b\n"+
+		"r\n\n", buf.String())
+}
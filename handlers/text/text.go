@@ -9,7 +9,9 @@ import (
 	"sync"
 
 	"github.com/eluv-io/apexlog-go"
-	"github.com/eluv-io/utc-go"
+	"github.com/eluv-io/log-go/handlers/internal/bufpool"
+	"github.com/eluv-io/log-go/handlers/internal/timestamp"
+	"github.com/eluv-io/log-go/handlers/internal/values"
 )
 
 // Default handler outputting to stderr.
@@ -27,8 +29,13 @@ var Levels = [...]string{
 
 // Handler implementation.
 type Handler struct {
-	mu     sync.Mutex
-	Writer io.Writer
+	mu           sync.Mutex
+	Writer       io.Writer
+	jsonValues   bool
+	jsonArrays   bool
+	messageWidth int    // 0: default width, < 0: no padding
+	separator    string // "": default separator
+	singleLine   bool   // true: escape newlines and tabs in error values
 }
 
 // New creates a new text handler
@@ -38,13 +45,78 @@ func New(w io.Writer) *Handler {
 	}
 }
 
+// WithJSONValues enables or disables rendering of complex field values
+// (structs and maps) as compact json instead of Go's default format.
+func (h *Handler) WithJSONValues(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.jsonValues = use
+	return h
+}
+
+// WithJSONArrays enables or disables rendering of slice and array field values
+// as compact json arrays (e.g. ["arg1","arg2"]) instead of Go's default format
+// (e.g. [arg1 arg2]), consistent with the json handlers.
+func (h *Handler) WithJSONArrays(use bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.jsonArrays = use
+	return h
+}
+
+// WithMessageWidth sets the width of the message column. Shorter messages are
+// padded with spaces, longer messages are not truncated. A width <= 0 disables
+// padding. Default: 25
+func (h *Handler) WithMessageWidth(n int) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n <= 0 {
+		n = -1
+	}
+	h.messageWidth = n
+	return h
+}
+
+// WithFieldSeparator sets the separator printed before each field. An empty
+// separator restores the default. Default: " "
+func (h *Handler) WithFieldSeparator(sep string) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.separator = sep
+	return h
+}
+
+// WithSingleLineErrors enables or disables escaping of newlines and tabs in
+// error values (e.g. nested errors and stacktraces) as literal \n and \t, so
+// that each log entry occupies exactly one line. Applies to the 'error' field
+// and to all other values of type error. Default: false
+func (h *Handler) WithSingleLineErrors(enable bool) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.singleLine = enable
+	return h
+}
+
 // HandleLog implements log.Handler.
 func (h *Handler) HandleLog(e *log.Entry) error {
 	level := Levels[e.Level]
 
-	sb := &strings.Builder{}
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
 
-	_, _ = fmt.Fprintf(sb, "%s %s %-25s", utc.Now().String(), level, e.Message)
+	width := h.messageWidth
+	switch {
+	case width == 0:
+		width = 25
+	case width < 0:
+		width = 0
+	}
+	sep := h.separator
+	if sep == "" {
+		sep = " "
+	}
+
+	_, _ = fmt.Fprintf(buf, "%s %s %-*s", timestamp.Of(e).String(), level, width, e.Message)
 
 	// print error field at the end, since they often have nested errors that
 	// are printed on separate lines
@@ -53,19 +125,50 @@ func (h *Handler) HandleLog(e *log.Entry) error {
 		if field.Name == "error" {
 			err = field.Value
 		} else {
-			_, _ = fmt.Fprintf(sb, " %s=%v", field.Name, field.Value)
+			_, _ = fmt.Fprintf(buf, "%s%s=%v", sep, field.Name, h.value(field.Value))
 		}
 	}
 	if err != nil {
-		_, _ = fmt.Fprintf(sb, " %s=%v", "error", err)
+		_, _ = fmt.Fprintf(buf, "%s%s=%v", sep, "error", h.errorValue(err))
 	}
 
-	_, _ = fmt.Fprintln(sb)
+	_, _ = fmt.Fprintln(buf)
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	_, _ = h.Writer.Write([]byte(sb.String()))
+	_, _ = h.Writer.Write(buf.Bytes())
 
 	return nil
 }
+
+// errorValue returns the value to render for the given error value, i.e. the
+// value of the 'error' field or any other value of type error.
+func (h *Handler) errorValue(err interface{}) interface{} {
+	err = values.Resolve(err)
+	if !h.singleLine {
+		return err
+	}
+	return singleLineReplacer.Replace(fmt.Sprint(err))
+}
+
+var singleLineReplacer = strings.NewReplacer("\r", `\r`, "\n", `\n`, "\t", `\t`)
+
+// value returns the value to render for the given field value.
+func (h *Handler) value(val interface{}) interface{} {
+	val = values.Resolve(val)
+	if _, ok := val.(error); ok {
+		return h.errorValue(val)
+	}
+	if h.jsonValues {
+		if s, ok := values.JSON(val); ok {
+			return s
+		}
+	}
+	if h.jsonArrays {
+		if s, ok := values.JSONArray(val); ok {
+			return s
+		}
+	}
+	return val
+}
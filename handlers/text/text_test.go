@@ -1,7 +1,17 @@
 package text_test
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
 	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/text"
 	"github.com/eluv-io/utc-go"
 )
 
@@ -28,3 +38,154 @@ func ExampleHandler() {
 	// 1970-01-01T00:00:00.000Z WARN  warn message              logger=/ field1=value1 field2=value2
 	// 1970-01-01T00:00:00.000Z ERROR error message             logger=/ field1=value1 field2=value2
 }
+
+// BenchmarkHandler benchmarks rendering of a log entry with the text handler.
+//
+// -- strings.Builder per entry
+// BenchmarkHandler   	 1000000	      1209 ns/op	     760 B/op	      13 allocs/op
+// -- pooled buffers
+// BenchmarkHandler   	 1262928	      1015 ns/op	     136 B/op	       8 allocs/op
+func BenchmarkHandler(b *testing.B) {
+	h := text.New(io.Discard)
+	e := &apex.Entry{
+		Level:   apex.InfoLevel,
+		Message: "account created",
+		Fields: apex.Fields{
+			{Name: "logger", Value: "/eluvio/log/sample"},
+			{Name: "account_id", Value: "456789"},
+			{Name: "account_name", Value: "Another Test Account"},
+			{Name: "count", Value: 12},
+		},
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = h.HandleLog(e)
+	}
+}
+
+func TestJSONValues(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	type address struct {
+		Street string
+		Zip    int
+	}
+	e := &apex.Entry{
+		Level:   apex.InfoLevel,
+		Message: "message",
+		Fields: apex.Fields{
+			{Name: "address", Value: address{Street: "Sesame Street 1", Zip: 99999}},
+			{Name: "counts", Value: map[string]int{"a": 1}},
+			{Name: "ts", Value: utc.UnixMilli(0)},
+			{Name: "name", Value: "me"},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	h := text.New(buf)
+	require.NoError(t, h.HandleLog(e))
+	require.Equal(t, "1970-01-01T00:00:00.000Z INFO  message                   "+
+		"address={Sesame Street 1 99999} counts=map[a:1] ts=1970-01-01T00:00:00.000Z name=me\n", buf.String())
+
+	buf.Reset()
+	h.WithJSONValues(true)
+	require.NoError(t, h.HandleLog(e))
+	require.Equal(t, "1970-01-01T00:00:00.000Z INFO  message                   "+
+		`address={"Street":"Sesame Street 1","Zip":99999} counts={"a":1} ts=1970-01-01T00:00:00.000Z name=me`+"\n", buf.String())
+}
+
+func TestMessageWidthAndSeparator(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	tests := []struct {
+		width int
+		sep   string
+		want  string
+	}{
+		{width: 0, sep: "", want: "1970-01-01T00:00:00.000Z INFO  message                   a=1 b=2\n"},
+		{width: 10, sep: "", want: "1970-01-01T00:00:00.000Z INFO  message    a=1 b=2\n"},
+		{width: 4, sep: "", want: "1970-01-01T00:00:00.000Z INFO  message a=1 b=2\n"},
+		{width: -1, sep: " | ", want: "1970-01-01T00:00:00.000Z INFO  message | a=1 | b=2\n"},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		h := text.New(buf).WithFieldSeparator(test.sep)
+		if test.width != 0 {
+			h.WithMessageWidth(test.width)
+		}
+		lg := &apex.Logger{Handler: h, Level: apex.InfoLevel}
+		lg.Info("message", "a", 1, "b", 2)
+		require.Equal(t, test.want, buf.String())
+	}
+
+	buf := &bytes.Buffer{}
+	lg := log.New(&log.Config{
+		Level:              "info",
+		Handler:            "text",
+		TextMessageWidth:   10,
+		TextFieldSeparator: ", ",
+	})
+	lg.Handler().(*text.Handler).Writer = buf
+	lg.Info("message", "a", 1)
+	require.Equal(t, "1970-01-01T00:00:00.000Z INFO  message   , logger=/, a=1\n", buf.String())
+}
+
+func TestSingleLineErrors(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	err := fmt.Errorf("op failed\n\tcaused by: %w", fmt.Errorf("inner failed\n\tat main.go:10"))
+	for _, single := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+		lg := &apex.Logger{Handler: text.New(buf).WithSingleLineErrors(single), Level: apex.InfoLevel}
+		lg.Error("failed", err, "a", 1)
+
+		out := buf.String()
+		if !single {
+			require.Equal(t, 3, strings.Count(out, "\n"), out)
+			continue
+		}
+		require.Equal(t, 1, strings.Count(out, "\n"), out)
+		require.Equal(t,
+			`1970-01-01T00:00:00.000Z ERROR failed                    a=1 error=op failed\n\tcaused by: inner failed\n\tat main.go:10`+"\n",
+			out)
+	}
+
+	// applies to error values of other fields
+	buf := &bytes.Buffer{}
+	require.NoError(t, text.New(buf).WithSingleLineErrors(true).HandleLog(&apex.Entry{
+		Level:   apex.WarnLevel,
+		Message: "retrying",
+		Fields:  apex.Fields{{Name: "cause", Value: err}, {Name: "text", Value: "a\nb"}},
+	}))
+	require.Equal(t,
+		`1970-01-01T00:00:00.000Z WARN  retrying                  cause=op failed\n\tcaused by: inner failed\n\tat main.go:10 text=a`+"\nb\n",
+		buf.String())
+}
+
+func TestJSONArrays(t *testing.T) {
+	defer utc.MockNow(utc.UnixMilli(0))()
+
+	e := &apex.Entry{
+		Level:   apex.InfoLevel,
+		Message: "message",
+		Fields: apex.Fields{
+			{Name: "args", Value: []string{"arg1", "arg2"}},
+			{Name: "ids", Value: [2]int{1, 2}},
+			{Name: "bytes", Value: []byte("ab")},
+			{Name: "name", Value: "me"},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	h := text.New(buf)
+	require.NoError(t, h.HandleLog(e))
+	require.Equal(t, "1970-01-01T00:00:00.000Z INFO  message                   "+
+		"args=[arg1 arg2] ids=[1 2] bytes=[97 98] name=me\n", buf.String())
+
+	buf.Reset()
+	h.WithJSONArrays(true)
+	require.NoError(t, h.HandleLog(e))
+	require.Equal(t, "1970-01-01T00:00:00.000Z INFO  message                   "+
+		`args=["arg1","arg2"] ids=[1,2] bytes=[97 98] name=me`+"\n", buf.String())
+}
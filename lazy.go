@@ -0,0 +1,50 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy wraps the given function as field value that is only resolved when an
+// entry is actually rendered by a handler, e.g. for values that are expensive
+// to compute:
+//
+//	log.Debug("state", "dump", log.Lazy(func() interface{} { return s.Dump() }))
+//
+// Since fields are only evaluated for enabled levels, the function is not
+// called at all for disabled levels. It is called at most once per value, even
+// if the entry is rendered by multiple handlers.
+func Lazy(fn func() interface{}) *LazyValue {
+	return &LazyValue{fn: fn}
+}
+
+// LazyValue is a field value that is resolved on first use, see Lazy.
+type LazyValue struct {
+	once     sync.Once
+	fn       func() interface{}
+	val      interface{}
+	resolved atomic.Bool // true once the value is resolved
+}
+
+// Resolve calls the wrapped function on first use and returns its result.
+func (v *LazyValue) Resolve() interface{} {
+	v.once.Do(func() {
+		if v.fn != nil {
+			v.val = v.fn()
+		}
+		v.resolved.Store(true)
+	})
+	return v.val
+}
+
+// String returns the resolved value formatted with fmt.Sprint.
+func (v *LazyValue) String() string {
+	return fmt.Sprint(v.Resolve())
+}
+
+// MarshalJSON returns the json representation of the resolved value.
+func (v *LazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Resolve())
+}
@@ -0,0 +1,54 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+)
+
+type countingStringer struct {
+	calls int
+}
+
+func (s *countingStringer) String() string {
+	s.calls++
+	return "expensive"
+}
+
+func TestLazy(t *testing.T) {
+	for _, handler := range []string{"text", "console", "json", "json-sorted", "raw", "gcp"} {
+		t.Run(handler, func(t *testing.T) {
+			debug := &bytes.Buffer{}
+			info := &bytes.Buffer{}
+			lg := log.NewLevelWriters(&log.Config{Level: "info", Handler: handler}, map[string]io.Writer{
+				"debug": debug,
+				"info":  info,
+				"info+": info,
+			})
+
+			resolved := 0
+			stringer := &countingStringer{}
+			lazy := func() *log.LazyValue {
+				return log.Lazy(func() interface{} {
+					resolved++
+					return stringer.String()
+				})
+			}
+
+			lg.Debug("disabled", "value", lazy())
+			require.Equal(t, 0, resolved)
+			require.Empty(t, debug.String())
+
+			// rendered by two handlers, but resolved once
+			lg.Info("enabled", "value", lazy())
+			require.Equal(t, 1, resolved)
+			require.Equal(t, 1, stringer.calls)
+			require.Equal(t, 2, strings.Count(info.String(), "expensive"), info.String())
+		})
+	}
+}
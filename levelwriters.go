@@ -0,0 +1,105 @@
+package log
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	apex "github.com/eluv-io/apexlog-go"
+)
+
+// NewLevelWriters creates a new root Logger that routes entries to the given
+// writers based on their level, e.g. errors to a network sink and everything
+// else to stdout. Each writer gets its own handler of the type configured in c.
+//
+// The keys of the writers map are level names ("trace", "debug", "info",
+// "warn", "error", "fatal"). A writer with a plain level name receives only
+// the entries at exactly that level. A writer whose level name is suffixed with
+// '+' (e.g. "warn+") is a threshold writer and receives the entries at that
+// level and all higher severity levels. An entry is written to all matching
+// writers. Entries without any matching writer are discarded, and keys with an
+// invalid level name are ignored.
+//
+// The File and CompressStream settings of the config are ignored, since the
+// writers replace the configured output. Other settings apply like in New, e.g.
+// DeferUntil or RecentEntries.
+func NewLevelWriters(c *Config, writers map[string]io.Writer) *Log {
+	cfg := *c
+	cfg.File = nil
+	cfg.CompressStream = nil
+
+	router := &levelRouter{}
+	for _, key := range sortedWriterKeys(writers) {
+		threshold := strings.HasSuffix(key, "+")
+		level, err := apex.ParseLevel(strings.TrimSuffix(key, "+"))
+		if err != nil {
+			continue
+		}
+		router.routes = append(router.routes, &levelRoute{
+			level:     level,
+			threshold: threshold,
+			handler:   newHandler(&cfg, writers[key]),
+		})
+	}
+
+	metrics().InstanceCreated()
+	ret := newLogWithHandler(&cfg, defaultFields(&cfg, "/"), deferHandler(&cfg, router))
+	keepRecentEntries(ret, &cfg, "/")
+	ret.setLevelSource("/", c.Level != "")
+	return ret
+}
+
+// levelRouter is a handler dispatching entries to the handlers of the routes
+// matching the entry's level.
+type levelRouter struct {
+	routes []*levelRoute
+}
+
+type levelRoute struct {
+	level     apex.Level   // the level of the route
+	threshold bool         // true if the route receives all entries at level or above
+	handler   apex.Handler // the handler writing to the route's writer
+}
+
+// matches returns true if the route receives entries at the given level.
+func (r *levelRoute) matches(level apex.Level) bool {
+	if r.threshold {
+		return level >= r.level
+	}
+	return level == r.level
+}
+
+// HandleLog implements apex.Handler and returns the first error of the
+// matching handlers.
+func (h *levelRouter) HandleLog(e *apex.Entry) error {
+	var err error
+	for _, route := range h.routes {
+		if !route.matches(e.Level) {
+			continue
+		}
+		if herr := route.handler.HandleLog(e); err == nil {
+			err = herr
+		}
+	}
+	return err
+}
+
+// Asynchronous returns true if any of the route handlers is asynchronous and
+// therefore requires entries to remain valid after HandleLog returns.
+func (h *levelRouter) Asynchronous() bool {
+	for _, route := range h.routes {
+		if a, ok := route.handler.(apex.Asynchronous); ok && a.Asynchronous() {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedWriterKeys(m map[string]io.Writer) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
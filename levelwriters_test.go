@@ -0,0 +1,76 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+)
+
+func TestLevelWriters(t *testing.T) {
+	debug := &bytes.Buffer{}
+	info := &bytes.Buffer{}
+	alerts := &bytes.Buffer{}
+	lg := log.NewLevelWriters(&log.Config{Level: "debug", Handler: "text"}, map[string]io.Writer{
+		"debug":   debug,
+		"info":    info,
+		"warn+":   alerts,
+		"invalid": &bytes.Buffer{},
+	})
+
+	lg.Trace("trace entry")
+	lg.Debug("debug entry")
+	lg.Info("info entry", "key", "value")
+	lg.Warn("warn entry")
+	lg.Error("error entry")
+
+	messages := func(buf *bytes.Buffer) []string {
+		var ret []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			for _, msg := range []string{"trace", "debug", "info", "warn", "error"} {
+				if strings.Contains(line, msg+" entry") {
+					ret = append(ret, msg)
+				}
+			}
+		}
+		return ret
+	}
+
+	require.Equal(t, []string{"debug"}, messages(debug))
+	require.Equal(t, []string{"info"}, messages(info))
+	require.Contains(t, info.String(), "key=value")
+	require.Equal(t, []string{"warn", "error"}, messages(alerts))
+}
+
+func TestLevelWritersConfig(t *testing.T) {
+	m := &metrics{}
+	log.SetMetrics(m)
+	defer log.SetMetrics(nil)
+
+	out := &bytes.Buffer{}
+	lg := log.NewLevelWriters(&log.Config{
+		Level:         "info",
+		Handler:       "text",
+		RecentEntries: 5,
+		DeferUntil:    "warn",
+	}, map[string]io.Writer{"info+": out})
+	require.Equal(t, 1, m.instances)
+
+	lg.Info("held back")
+	require.Empty(t, out.String())
+	lg.Warn("trigger")
+	require.Contains(t, out.String(), "held back")
+	require.Contains(t, out.String(), "trigger")
+
+	recent := log.RecentEntriesFor("/", 2)
+	require.Len(t, recent, 2)
+	require.Equal(t, "held back", recent[0].Message)
+	require.Equal(t, "trigger", recent[1].Message)
+}
@@ -39,7 +39,7 @@ package log
 import (
 	"reflect"
 	"runtime"
-	"strings"
+	"sync"
 	"sync/atomic"
 
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -49,7 +49,9 @@ import (
 
 // New creates a new root Logger
 func New(c *Config) *Log {
-	return newLog(c, defaultFields(c, "/"), nil)
+	ret := newNamedLog(c, "/", nil)
+	ret.setLevelSource("/", c.Level != "")
+	return ret
 }
 
 func NewLumberjackLogger(c *LumberjackConfig) *lumberjack.Logger {
@@ -66,10 +68,16 @@ func NewLumberjackLogger(c *LumberjackConfig) *lumberjack.Logger {
 // Log provides the fundamental logging functions. It's implemented as a wrapper around the actual logger implementation
 // that allows concurrency-safe modification (replacement) of the underlying logger.
 type Log struct {
-	lw atomic.Pointer[logger]
+	lw      atomic.Pointer[logger]
+	meta    sync.Map    // opaque metadata, see SetMeta
+	bursts  sync.Map    // throttled loggers by key, see Burst - unused in derived logs
+	derived *derivedLog // non-nil for logs with base fields, see WithMap
 }
 
 func (l *Log) get() *logger {
+	if l.derived != nil {
+		return l.derived.get()
+	}
 	return l.lw.Load()
 }
 
@@ -83,6 +91,67 @@ func (l *Log) Handler() apex.Handler {
 	return l.get().handler()
 }
 
+// HandlerName returns the name of the handler this Log was built with, i.e. the
+// handler configured with Config.Handler (directly or inherited from a parent
+// config) resolved to the handler actually used: empty or unknown names resolve
+// to "json", the fallback handler. Handlers set with SetHandler are not
+// reflected.
+func (l *Log) HandlerName() string {
+	return resolveHandlerName(l.get().config.Handler)
+}
+
+// SetHandler atomically replaces the handler of this Log with the given handler.
+// Unlike modifying the handler returned by Handler(), it is safe to call while
+// other goroutines are logging. Loggers that share the previous handler, e.g.
+// named loggers created with Get, are not affected. The output owned by this
+// Log (a log file) is not closed.
+func (l *Log) SetHandler(h apex.Handler) {
+	if l.derived != nil {
+		l.derived.parent.SetHandler(h)
+		return
+	}
+	root := l.getLogRoot()
+	root.doLocked(func(r *logRoot) {
+		l.set(l.get().copy(func(lg *logger) {
+			al := lg.logger()
+			al.Handler = replaceHandler(al.Handler, h)
+		}))
+	})
+}
+
+// SetMeta stores the given metadata under the given key with this Log. The
+// metadata is not logged, but allows frameworks to associate their own state
+// with a logger. It is retained when the logger is reconfigured. A nil value
+// removes the key.
+func (l *Log) SetMeta(key string, value interface{}) {
+	if value == nil {
+		l.meta.Delete(key)
+		return
+	}
+	l.meta.Store(key, value)
+}
+
+// Meta returns the metadata stored under the given key with SetMeta, or nil if
+// there is none.
+func (l *Log) Meta(key string) interface{} {
+	val, _ := l.meta.Load(key)
+	return val
+}
+
+// Close closes the log file of this Log, if any. Further entries re-open the
+// file. Logs sharing the file of a parent Log do not own the file and are not
+// affected. Calling Close repeatedly or on a Log without file is safe.
+func (l *Log) Close() error {
+	return l.get().close()
+}
+
+// Sync flushes buffered output of this Log, i.e. the compressed data if
+// CompressStream is enabled. Like Close, it only affects Logs owning their
+// output.
+func (l *Log) Sync() error {
+	return l.get().sync()
+}
+
 func (l *Log) updateFrom(nl *Log) {
 	l.lw.Store(nl.lw.Load())
 }
@@ -117,6 +186,176 @@ func (l *Log) Fatal(msg string, fields ...interface{}) {
 	l.get().Fatal(msg, fields...)
 }
 
+// Always logs the given message at the given level regardless of the level of
+// this Log, e.g. for audit or security events that must always be recorded.
+// The level of the Log is not changed. Entries are not suppressed by
+// EnableOnlyGID, but filtering applied after the level check still applies,
+// e.g. the global rate limit or filtering handlers. Unknown
+// levels log at the Info level, and "fatal" logs at the Error level without
+// exiting.
+func (l *Log) Always(level string, msg string, fields ...interface{}) {
+	lvl, err := apex.ParseLevel(level)
+	if err != nil {
+		lvl = apex.InfoLevel
+	}
+	lg := l.get().copy(func(lg *logger) {
+		lg.logger().Level = apex.TraceLevel
+		lg.always = true
+	})
+	switch lvl {
+	case apex.TraceLevel:
+		lg.Trace(msg, fields...)
+	case apex.DebugLevel:
+		lg.Debug(msg, fields...)
+	case apex.InfoLevel:
+		lg.Info(msg, fields...)
+	case apex.WarnLevel:
+		lg.Warn(msg, fields...)
+	default:
+		lg.Error(msg, fields...)
+	}
+}
+
+// TraceFunc logs the given message at the Trace level with the fields
+// returned by fn. fn is only invoked if the Trace level is enabled, avoiding
+// the cost of computing fields that would be discarded:
+//
+//	log.TraceFunc("state", func() []interface{} { return dumpState() })
+func (l *Log) TraceFunc(msg string, fn func() []interface{}) {
+	if lg := l.get(); lg.IsTrace() {
+		lg.Trace(msg, invoke(fn)...)
+	}
+}
+
+// DebugFunc logs the given message at the Debug level with the fields
+// returned by fn. fn is only invoked if the Debug level is enabled.
+func (l *Log) DebugFunc(msg string, fn func() []interface{}) {
+	if lg := l.get(); lg.IsDebug() {
+		lg.Debug(msg, invoke(fn)...)
+	}
+}
+
+// InfoFunc logs the given message at the Info level with the fields returned
+// by fn. fn is only invoked if the Info level is enabled.
+func (l *Log) InfoFunc(msg string, fn func() []interface{}) {
+	if lg := l.get(); lg.IsInfo() {
+		lg.Info(msg, invoke(fn)...)
+	}
+}
+
+// WarnFunc logs the given message at the Warn level with the fields returned
+// by fn. fn is only invoked if the Warn level is enabled.
+func (l *Log) WarnFunc(msg string, fn func() []interface{}) {
+	if lg := l.get(); lg.IsWarn() {
+		lg.Warn(msg, invoke(fn)...)
+	}
+}
+
+// ErrorFunc logs the given message at the Error level with the fields
+// returned by fn. fn is only invoked if the Error level is enabled.
+func (l *Log) ErrorFunc(msg string, fn func() []interface{}) {
+	if lg := l.get(); lg.IsError() {
+		lg.Error(msg, invoke(fn)...)
+	}
+}
+
+// invoke returns the fields returned by fn or nil if fn is nil.
+func invoke(fn func() []interface{}) []interface{} {
+	if fn == nil {
+		return nil
+	}
+	return fn()
+}
+
+// Panic logs the given message at the Error level and then panics with the
+// message. Unlike Fatal, which exits the process, the panic may be recovered
+// by the caller, e.g. the request handling of a server. Before panicking, the
+// buffered output of all logs is flushed (see Sync), so that the entry is
+// persisted even if the panic is not recovered.
+func (l *Log) Panic(msg string, fields ...interface{}) {
+	l.get().Error(msg, fields...)
+	syncCompressedOutputs()
+	panic(msg)
+}
+
+// Log logs the given message at the given level, e.g. "warn". Useful if the
+// level is determined at runtime:
+//
+//	level := "warn"
+//	if retries > maxRetries {
+//		level = "error"
+//	}
+//	lg.Log(level, "retrying request", "retries", retries)
+//
+// An invalid level is treated as Info.
+func (l *Log) Log(level string, msg string, fields ...interface{}) {
+	lvl, err := apex.ParseLevel(level)
+	if err != nil {
+		lvl = apex.InfoLevel
+	}
+	lg := l.get()
+	switch lvl {
+	case apex.TraceLevel:
+		lg.Trace(msg, fields...)
+	case apex.DebugLevel:
+		lg.Debug(msg, fields...)
+	case apex.InfoLevel:
+		lg.Info(msg, fields...)
+	case apex.WarnLevel:
+		lg.Warn(msg, fields...)
+	case apex.ErrorLevel:
+		lg.Error(msg, fields...)
+	case apex.FatalLevel:
+		lg.Fatal(msg, fields...)
+	}
+}
+
+// ErrorE logs the given message and error at the Error level and returns the
+// error. Useful at return sites:
+//
+//	return log.ErrorE(err, "failed to create account", "account_id", id)
+//
+// The entry is logged even if the error is nil, see WrapError for a variant
+// that logs only non-nil errors.
+func (l *Log) ErrorE(err error, msg string, fields ...interface{}) error {
+	l.get().Error(msg, withError(err, fields)...)
+	return err
+}
+
+// WrapError is like ErrorE (and takes the same arguments), but logs nothing and
+// returns nil if the error is nil, so it can wrap the result of a call
+// unconditionally:
+//
+//	return log.WrapError(createAccount(id), "failed to create account", "account_id", id)
+func (l *Log) WrapError(err error, msg string, fields ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return l.ErrorE(err, msg, fields...)
+}
+
+// WarnE logs the given message and error at the Warn level and returns the
+// error.
+func (l *Log) WarnE(err error, msg string, fields ...interface{}) error {
+	l.get().Warn(msg, withError(err, fields)...)
+	return err
+}
+
+// InfoE logs the given message and error at the Info level and returns the
+// error.
+func (l *Log) InfoE(err error, msg string, fields ...interface{}) error {
+	l.get().Info(msg, withError(err, fields)...)
+	return err
+}
+
+// withError prepends the given error to the fields unless it is nil.
+func withError(err error, fields []interface{}) []interface{} {
+	if err == nil {
+		return fields
+	}
+	return append([]interface{}{err}, fields...)
+}
+
 // IsTrace returns true if the logger logs in Trace level.
 func (l *Log) IsTrace() bool {
 	return l.get().IsTrace()
@@ -156,6 +395,24 @@ func (l *Log) Level() string {
 	return l.get().logger().Level.String()
 }
 
+// LevelSource returns the path of the logger that supplied the effective level
+// of this logger: the logger itself or the closest ancestor with a level in
+// its named config or set programmatically (e.g. with SetLevel), otherwise the
+// root logger. explicit is false if the level is the default level, because
+// none was configured at the returned path.
+func (l *Log) LevelSource() (path string, explicit bool) {
+	lg := l.get()
+	return lg.levelSource, lg.levelExplicit
+}
+
+// setLevelSource sets the source of the level of this log. Must only be called
+// before the log is in use.
+func (l *Log) setLevelSource(path string, explicit bool) {
+	lg := l.get()
+	lg.levelSource = path
+	lg.levelExplicit = explicit
+}
+
 // SetLevel sets the log level according to the given string.
 func (l *Log) SetLevel(level string) {
 	lvl, err := apex.ParseLevel(level)
@@ -200,22 +457,13 @@ func (l *Log) getLogRoot() *logRoot {
 }
 
 func (l *Log) setLogLevel(level apex.Level) {
-	setLevel := func(logCopy *logger) {
-		logCopy.logger().Level = level
-		logCopy.config.Level = level.String()
+	if l.derived != nil {
+		l.derived.parent.setLogLevel(level)
+		return
 	}
-	logName := l.get().name
-
 	root := l.getLogRoot()
 	root.doLocked(func(r *logRoot) {
-		for name, log := range r.named {
-			oldLogger := log.get()
-			if strings.HasPrefix(name, logName) {
-				newLogger := oldLogger.copy(setLevel)
-				log.set(newLogger)
-			}
-		}
-		l.set(l.get().copy(setLevel))
+		r.setLevelNoLock(l, level)
 	})
 }
 
@@ -1,5 +1,16 @@
 package log
 
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
 var (
 	trueValue  = true
 	falseValue = false
@@ -17,18 +28,398 @@ type Config struct {
 	// Handler specifies the log handler to use. Default: json
 	Handler string `json:"formatter"`
 
-	// File specifies the log file settings. Default: nil (log to stdout)
+	// File specifies the log file settings. Default: nil (log to Output)
 	File *LumberjackConfig `json:"file,omitempty"`
 
+	// Output is the output of logs without File: "stdout" or "stderr".
+	// Default: "stdout"
+	Output string `json:"output,omitempty"`
+
+	// Banner writes a "log started" entry with the process name, pid, version
+	// and start time at the start of each new log file, i.e. before the first
+	// entry written to a new or empty file and after each rotation, so that a
+	// truncated file is self-identifying. The entry is rendered by the
+	// configured handler. Ignored if File is not set and with the audit
+	// handler. Default: false
+	Banner *bool `json:"banner,omitempty"`
+
+	// DeferUntil enables the "quiet until error" mode: entries below the given
+	// level are held back and only written, together with the preceding held
+	// back entries, once an entry at or above the given level is logged. See
+	// package handlers/deferred. Default: "" (disabled)
+	DeferUntil string `json:"defer_until,omitempty"`
+
+	// CompressStream compresses the output (file or stdout) with gzip. The
+	// compressed data is flushed periodically, with Sync and on Close. Unlike
+	// the Compress option of the file config, which compresses rotated backup
+	// files, this compresses the current output. Meant for output that is not
+	// rotated. Logs writing to the same file must either all compress their
+	// output or none of them. Logs compressing the same output stream (stdout
+	// or stderr) share a single gzip stream, which is completed when the
+	// default configuration is re-applied. Default: false
+	CompressStream *bool `json:"compress_stream,omitempty"`
+
+	// TextMessageWidth is the width of the message column of the text handler.
+	// A negative width disables padding. Default: 0 (25 characters)
+	TextMessageWidth int `json:"text_message_width,omitempty"`
+
+	// TextFieldSeparator is the separator printed before each field by the text
+	// handler. Default: "" (a single space)
+	TextFieldSeparator string `json:"text_field_separator,omitempty"`
+
+	// RawField is the name of the field printed without label on a separate
+	// line by the raw handler. Default: "" (the field "raw")
+	RawField string `json:"raw_field,omitempty"`
+
+	// Prefix is a tag prepended to the message of all entries, separated by a
+	// space, e.g. "[CACHE]". Only applied with the text, console and raw
+	// handlers - the message of structured output remains unchanged. Default:
+	// "" (no prefix)
+	Prefix string `json:"prefix,omitempty"`
+
+	// ConsoleStart is the start time used as baseline for the offsets printed
+	// by the console handler. Default: nil (the start of the process, which
+	// keeps offsets monotonic when handlers are re-created on config changes)
+	ConsoleStart *utc.UTC `json:"console_start,omitempty"`
+
 	// Include go routine ID as 'gid' in logged fields
 	GoRoutineID *bool `json:"go_routine_id,omitempty"`
 
-	// Include caller info (file:line) as 'caller' in logged fields
+	// GoRoutineIDKey is the field name used for the go routine ID. Default: gid
+	GoRoutineIDKey string `json:"go_routine_id_key,omitempty"`
+
+	// RootLoggerField controls whether the root logger adds the 'logger=/'
+	// field, regardless of the handler. Only evaluated in the root config.
+	// Default: nil (field added except for the console handler and the memory
+	// handler at levels other than debug)
+	RootLoggerField *bool `json:"root_logger_field,omitempty"`
+
+	// Include the hostname as 'host' in logged fields
+	IncludeHostname *bool `json:"include_hostname,omitempty"`
+
+	// Include the version and commit set with SetVersionInfo as 'version' and
+	// 'commit' in logged fields
+	IncludeVersion *bool `json:"include_version,omitempty"`
+
+	// Include the number of goroutines as 'goroutines' in logged fields, e.g. for
+	// chasing goroutine leaks. Since counting the goroutines has a cost, the
+	// count is sampled at most every 100ms and may therefore be slightly stale.
+	IncludeGoroutineCount *bool `json:"include_goroutine_count,omitempty"`
+
+	// BytesFormat is the format of []byte values in logged fields: "hex" (with
+	// 0x prefix), "base64" or "string". Default: hex
+	BytesFormat string `json:"bytes_format,omitempty"`
+
+	// EnforceUTF8 replaces invalid UTF-8 in the message and string field values
+	// with the Unicode replacement character. Default: false
+	EnforceUTF8 *bool `json:"enforce_utf8,omitempty"`
+
+	// FieldTypes maps field keys to the type their values are converted to for
+	// a consistent representation: "int", "float" or "string". Only numbers are
+	// converted to "int" and "float". Default: nil (no conversion)
+	FieldTypes map[string]string `json:"field_types,omitempty"`
+
+	// OmitEmptyFields drops the fields of log calls whose value is an empty
+	// string, nil or a nil pointer. Zero numbers and false booleans are kept.
+	// Default: false
+	OmitEmptyFields *bool `json:"omit_empty_fields,omitempty"`
+
+	// MaxFields is the maximum number of fields of a log call. Further fields
+	// are dropped and their number is added as 'fields_truncated' field. The
+	// base fields of a logger (e.g. 'logger', 'gid') are always kept.
+	// Default: 0 (no limit)
+	MaxFields int `json:"max_fields,omitempty"`
+
+	// StrictFields logs a "malformed log fields" warning with the caller for
+	// log calls with malformed fields: an odd-length list, whose last value is
+	// logged with the key "unknown", or keys that are not strings. The fields
+	// are still logged as usual. Meant for tests and development.
+	// Default: false
+	StrictFields *bool `json:"strict_fields,omitempty"`
+
+	// Sequence adds a 'seq' field with a monotonically increasing sequence
+	// number to all entries. The counter is shared by all loggers of the
+	// process and continues across configuration changes, so that gaps
+	// indicate lost entries. Default: false
+	Sequence *bool `json:"sequence,omitempty"`
+
+	// ExtractErrorKind adds the kind of the first error of a log call, with or
+	// without key, as 'error_kind' field if the error or one of its causes has
+	// a kind, like the errors of eluv-io/errors-go. The error itself is logged
+	// unchanged. Default: false
+	ExtractErrorKind *bool `json:"extract_error_kind,omitempty"`
+
+	// Include caller info (file:line) as 'caller' in logged fields, see
+	// CallerInfo. The json handlers of this package (e.g. "json-sorted") log it
+	// as object with file, line and function if configured with
+	// WithStructuredCaller.
 	Caller *bool `json:"caller,omitempty"`
 
+	// CallerMinLevel limits the caller info to entries at or above the given
+	// level (e.g. "warn"), avoiding the cost of capturing it on the hot debug
+	// and info paths. If set, caller info is added regardless of Caller. An
+	// invalid level is ignored. Default: "" (Caller applies to all levels)
+	CallerMinLevel string `json:"caller_min_level,omitempty"`
+
+	// RecentEntries is the number of most recent entries kept in memory per
+	// logger for retrieval with RecentEntriesFor. Default: 0 (disabled)
+	RecentEntries int `json:"recent_entries,omitempty"`
+
+	// CrashBuffer is the number of most recent log lines kept in a process-wide
+	// ring buffer for crash reports, see DumpCrashBuffer. The lines of all
+	// loggers are captured, rendered like the text handler renders them.
+	// The buffer is enabled by default, since the lines preceding a crash are
+	// only available if they were captured before anyone anticipated it.
+	// Rendering each emitted entry a second time costs about 1.5µs per entry
+	// (see BenchmarkCrashBuffer): a negative value disables the crash buffer,
+	// e.g. for processes logging at high rates. Only evaluated in the root
+	// config set with SetDefault. Default: 0 (100 lines)
+	CrashBuffer int `json:"crash_buffer,omitempty"`
+
+	// PreserveLevelOverrides re-applies the levels set programmatically at
+	// runtime (e.g. with SetDebug()) after this config is set with SetDefault.
+	// Otherwise, all levels are reset to the configured ones. Only evaluated in
+	// the root config. Default: false
+	PreserveLevelOverrides *bool `json:"preserve_level_overrides,omitempty"`
+
+	// LogConfigDiff logs a single entry describing the changes of level,
+	// handler and file of all loggers when this config is set with SetDefault.
+	// Only evaluated in the root config. Default: false
+	LogConfigDiff *bool `json:"log_config_diff,omitempty"`
+
+	// Strict validates this config with Validate when it is set with
+	// SetDefault. An invalid config is rejected: the error is logged and the
+	// current config remains in effect. Only evaluated in the root config.
+	// Default: false
+	Strict *bool `json:"strict,omitempty"`
+
+	// UseEnv applies the environment variables with ApplyEnv (modifying this
+	// config) when it is set with SetDefault. Only evaluated in the root config.
+	// Default: false
+	UseEnv *bool `json:"use_env,omitempty"`
+
 	// Named contains the configuration of named loggers.
 	// Any nested "Named" elements are ignored.
 	Named map[string]*Config `json:"named,omitempty"`
+
+	// handler is used instead of a handler created from Handler if set - it is
+	// inherited by named loggers, see SetDefaultMemory.
+	handler apex.Handler
+}
+
+// ApplyEnv overrides the config with the following environment variables, if
+// set:
+//
+//	LOG_LEVEL           the level of the root logger
+//	LOG_HANDLER         the handler of the root logger
+//	LOG_FILE            the log file of the root logger
+//	LOG_LEVEL_<path>    the level of the named logger with the given path,
+//	                    e.g. LOG_LEVEL_/http-req=debug
+//
+// Environment variables take precedence over the values of the config, e.g.
+// as read from a config file. The config is modified in place and returned.
+func (c *Config) ApplyEnv() *Config {
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		c.Level = level
+	}
+	if handler := os.Getenv("LOG_HANDLER"); handler != "" {
+		c.Handler = handler
+	}
+	if filename := os.Getenv("LOG_FILE"); filename != "" {
+		file := LumberjackConfig{}
+		if c.File != nil {
+			file = *c.File
+		}
+		file.Filename = filename
+		c.File = &file
+	}
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "LOG_LEVEL_") {
+			continue
+		}
+		path, level, found := strings.Cut(strings.TrimPrefix(env, "LOG_LEVEL_"), "=")
+		if !found || path == "" || level == "" {
+			continue
+		}
+		if path[0] != '/' {
+			path = "/" + path
+		}
+		if c.Named == nil {
+			c.Named = make(map[string]*Config)
+		}
+		named := &Config{}
+		if c.Named[path] != nil {
+			*named = *c.Named[path]
+		}
+		named.Level = level
+		c.Named[path] = named
+	}
+	return c
+}
+
+// Validate checks the config for unknown handler names, invalid levels and
+// named configs with their own nested named configs, which would otherwise be
+// silently replaced by defaults or ignored.
+func (c *Config) Validate() error {
+	err := c.validate("/")
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(c.Named))
+	for path := range c.Named {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		named := c.Named[path]
+		if named == nil {
+			continue
+		}
+		err = named.validate(path)
+		if err != nil {
+			return err
+		}
+		if len(named.Named) > 0 {
+			return errors.E("Config.Validate", errors.K.Invalid,
+				"reason", "nested named configs are not supported",
+				"logger", path)
+		}
+	}
+	return c.validateSharedFiles(paths)
+}
+
+// validateSharedFiles returns an error if logs writing to the same file differ
+// in their CompressStream setting, since mixing compressed and uncompressed
+// output corrupts the file. The settings of the named loggers are merged with
+// those of their ancestors like in updateNamedLoggers.
+func (c *Config) validateSharedFiles(paths []string) error {
+	type usage struct {
+		logger   string
+		compress bool
+	}
+	files := map[string]usage{}
+	for _, path := range append([]string{"/"}, paths...) {
+		file, compress := c.File, c.CompressStream
+		for idx := 0; idx < len(path) && path != "/"; {
+			idx++ // skip the "current" separator
+			if i := strings.Index(path[idx:], "/"); i != -1 {
+				idx += i
+			} else {
+				idx = len(path)
+			}
+			if cfg := c.Named[path[:idx]]; cfg != nil {
+				if cfg.File != nil {
+					file = cfg.File
+				}
+				if cfg.CompressStream != nil {
+					compress = cfg.CompressStream
+				}
+			}
+		}
+		if file == nil || (file.Filename == "" && !file.UseTempFile) {
+			continue
+		}
+		name, err := filepath.Abs(file.path())
+		if err != nil {
+			name = filepath.Clean(file.path())
+		}
+		u := usage{logger: path, compress: compress != nil && *compress}
+		if prev, found := files[name]; !found {
+			files[name] = u
+		} else if prev.compress != u.compress {
+			return errors.E("Config.Validate", errors.K.Invalid,
+				"reason", "shared file with mixed compression",
+				"logger", path,
+				"other_logger", prev.logger,
+				"file", name)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate(path string) error {
+	if c.Level != "" && c.Level != "normal" {
+		if _, err := apex.ParseLevel(c.Level); err != nil {
+			return errors.E("Config.Validate", errors.K.Invalid, err,
+				"reason", "invalid level",
+				"logger", path,
+				"level", c.Level)
+		}
+	}
+	if c.DeferUntil != "" {
+		if _, err := apex.ParseLevel(c.DeferUntil); err != nil {
+			return errors.E("Config.Validate", errors.K.Invalid, err,
+				"reason", "invalid defer level",
+				"logger", path,
+				"defer_until", c.DeferUntil)
+		}
+	}
+	switch c.Output {
+	case "", "stdout", "stderr":
+	default:
+		return errors.E("Config.Validate", errors.K.Invalid,
+			"reason", "invalid output",
+			"logger", path,
+			"output", c.Output)
+	}
+	if c.CallerMinLevel != "" {
+		if _, err := apex.ParseLevel(c.CallerMinLevel); err != nil {
+			return errors.E("Config.Validate", errors.K.Invalid, err,
+				"reason", "invalid caller level",
+				"logger", path,
+				"caller_min_level", c.CallerMinLevel)
+		}
+	}
+	for key, typ := range c.FieldTypes {
+		switch typ {
+		case "int", "float", "string":
+		default:
+			return errors.E("Config.Validate", errors.K.Invalid,
+				"reason", "unknown field type",
+				"logger", path,
+				"field", key,
+				"type", typ)
+		}
+	}
+	if c.Handler != "" && !isKnownHandler(c.Handler) {
+		return errors.E("Config.Validate", errors.K.Invalid,
+			"reason", "unknown handler",
+			"logger", path,
+			"handler", c.Handler,
+			"known", HandlerNames())
+	}
+	return nil
+}
+
+// handlerNames are the names of all built-in handlers.
+var handlerNames = []string{"text", "raw", "console", "discard", "memory", "json", "json-pretty", "json-ordered", "json-sorted", "gcp", "cloudwatch", "audit", "cloudevents"}
+
+func isBuiltinHandler(name string) bool {
+	for _, n := range handlerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveHandlerName returns the name of the handler created for the given
+// handler name: the name itself if it is known, "json" otherwise.
+func resolveHandlerName(name string) string {
+	if isKnownHandler(name) {
+		return name
+	}
+	return "json"
+}
+
+// isKnownHandler returns true if the given name is the name of a built-in or
+// registered handler.
+func isKnownHandler(name string) bool {
+	if isBuiltinHandler(name) {
+		return true
+	}
+	_, ok := registeredHandlers.Load(name)
+	return ok
 }
 
 func (c *Config) InitDefaults() *Config {
@@ -45,10 +436,15 @@ var Stdout = &LumberjackConfig{}
 
 type LumberjackConfig struct {
 	// Filename is the file to write logs to.  Backup log files will be retained
-	// in the same directory.  It uses <processname>-lumberjack.log in
-	// os.TempDir() if empty.
+	// in the same directory.  If empty, logs are written to the configured
+	// Output (stdout by default), unless UseTempFile is set.
 	Filename string `json:"filename"`
 
+	// UseTempFile opts into lumberjack's default file if Filename is empty:
+	// <processname>-lumberjack.log in os.TempDir(). Ignored if Filename is
+	// set. Default: false
+	UseTempFile bool `json:"use_temp_file,omitempty"`
+
 	// MaxSize is the maximum size in megabytes of the log file before it gets
 	// rotated. It defaults to 100 megabytes.
 	MaxSize int `json:"maxsize"`
@@ -74,3 +470,12 @@ type LumberjackConfig struct {
 	// using gzip. The default is not to perform compression.
 	Compress bool `json:"compress"`
 }
+
+// path returns the path of the log file: the Filename or lumberjack's default
+// file in os.TempDir() if empty.
+func (c *LumberjackConfig) path() string {
+	if c.Filename != "" {
+		return c.Filename
+	}
+	return filepath.Join(os.TempDir(), filepath.Base(os.Args[0])+"-lumberjack.log")
+}
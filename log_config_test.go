@@ -0,0 +1,259 @@
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/log-go"
+)
+
+func TestConfigValidate(t *testing.T) {
+	trueVal, falseVal := true, false
+	tests := []struct {
+		name   string
+		config *log.Config
+		reason string
+	}{
+		{
+			name:   "defaults",
+			config: log.NewConfig(),
+		},
+		{
+			name: "valid",
+			config: &log.Config{
+				Level:   "debug",
+				Handler: "text",
+				Named: map[string]*log.Config{
+					"/db": {Level: "warn", Handler: "json-pretty"},
+					"/ui": {},
+				},
+			},
+		},
+		{
+			name:   "unknown handler",
+			config: &log.Config{Handler: "jsn"},
+			reason: "unknown handler",
+		},
+		{
+			name:   "invalid level",
+			config: &log.Config{Level: "verbose"},
+			reason: "invalid level",
+		},
+		{
+			name: "unknown handler in named config",
+			config: &log.Config{
+				Named: map[string]*log.Config{"/db": {Handler: "txt"}},
+			},
+			reason: "unknown handler",
+		},
+		{
+			name: "invalid level in named config",
+			config: &log.Config{
+				Named: map[string]*log.Config{"/db": {Level: "loud"}},
+			},
+			reason: "invalid level",
+		},
+		{
+			name:   "unknown field type",
+			config: &log.Config{FieldTypes: map[string]string{"gid": "integer"}},
+			reason: "unknown field type",
+		},
+		{
+			name:   "invalid defer level",
+			config: &log.Config{DeferUntil: "panic"},
+			reason: "invalid defer level",
+		},
+		{
+			name:   "invalid caller level",
+			config: &log.Config{CallerMinLevel: "sometimes"},
+			reason: "invalid caller level",
+		},
+		{
+			name:   "invalid output",
+			config: &log.Config{Output: "stdlog"},
+			reason: "invalid output",
+		},
+		{
+			name: "nested named config",
+			config: &log.Config{
+				Named: map[string]*log.Config{
+					"/db": {
+						Named: map[string]*log.Config{"/db/sql": {Level: "debug"}},
+					},
+				},
+			},
+			reason: "nested named configs are not supported",
+		},
+		{
+			name: "shared file with same compression",
+			config: &log.Config{
+				File:           &log.LumberjackConfig{Filename: "/var/log/app.log"},
+				CompressStream: &trueVal,
+				Named: map[string]*log.Config{
+					"/db":     {Handler: "text"},
+					"/db/sql": {File: &log.LumberjackConfig{Filename: "/var/log/sql.log"}, CompressStream: &falseVal},
+				},
+			},
+		},
+		{
+			name: "shared file with mixed compression",
+			config: &log.Config{
+				File: &log.LumberjackConfig{Filename: "/var/log/app.log"},
+				Named: map[string]*log.Config{
+					"/db":     {CompressStream: &trueVal},
+					"/db/sql": {File: &log.LumberjackConfig{Filename: "/var/log/sql.log"}},
+				},
+			},
+			reason: "shared file with mixed compression",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Validate()
+			if test.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.True(t, errors.IsKind(errors.K.Invalid, err))
+			require.Contains(t, err.Error(), test.reason)
+		})
+	}
+}
+
+func TestConfigStrict(t *testing.T) {
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "memory",
+	})
+	defer log.SetDefault(log.NewConfig())
+	handler := log.Root().Handler().(*memory.Handler)
+
+	strict := true
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "jsn",
+		Strict:  &strict,
+	})
+
+	// the invalid config is rejected and reported with the current config
+	require.Same(t, handler, log.Root().Handler())
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "invalid log config - ignored", handler.Entries[0].Message)
+}
+
+func TestUnknownHandlerWarning(t *testing.T) {
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "memory",
+		Named: map[string]*log.Config{
+			"/unknown/a": {Handler: "jsom-test"},
+			"/unknown/b": {Handler: "jsom-test"},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+	handler := log.Root().Handler().(*memory.Handler)
+
+	log.Get("/unknown/a")
+	log.Get("/unknown/b")
+
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "unknown log handler - using json", handler.Entries[0].Message)
+	require.Equal(t, "jsom-test", handler.Entries[0].Fields.Get("handler"))
+}
+
+func TestRootLoggerField(t *testing.T) {
+	for _, handler := range []string{"text", "json", "console", "memory"} {
+		for _, level := range []string{"info", "debug"} {
+			for _, enabled := range []bool{true, false} {
+				enabled := enabled
+				f := filepath.Join(t.TempDir(), "test.log")
+				lg := log.New(&log.Config{
+					Level:           level,
+					Handler:         handler,
+					File:            &log.LumberjackConfig{Filename: f},
+					RootLoggerField: &enabled,
+				})
+				lg.Info("message")
+				require.NoError(t, lg.Close())
+
+				var found bool
+				if mh, ok := lg.Handler().(*memory.Handler); ok {
+					require.Equal(t, 1, len(mh.Entries))
+					found = mh.Entries[0].Fields.Get("logger") != nil
+				} else {
+					bts, err := os.ReadFile(f)
+					require.NoError(t, err)
+					found = strings.Contains(string(bts), "logger")
+				}
+				require.Equal(t, enabled, found, "handler %s level %s", handler, level)
+			}
+		}
+	}
+}
+
+func TestConfigApplyEnv(t *testing.T) {
+	newConfig := func() *log.Config {
+		return &log.Config{
+			Level:   "info",
+			Handler: "text",
+			File:    &log.LumberjackConfig{Filename: "/var/log/app.log", MaxSize: 10},
+			Named: map[string]*log.Config{
+				"/db": {Level: "warn", Handler: "json"},
+			},
+		}
+	}
+
+	t.Run("no env", func(t *testing.T) {
+		t.Setenv("LOG_LEVEL", "")
+		t.Setenv("LOG_HANDLER", "")
+		t.Setenv("LOG_FILE", "")
+		require.Equal(t, newConfig(), newConfig().ApplyEnv())
+	})
+	t.Run("LOG_LEVEL", func(t *testing.T) {
+		t.Setenv("LOG_LEVEL", "debug")
+		require.Equal(t, "debug", newConfig().ApplyEnv().Level)
+	})
+	t.Run("LOG_HANDLER", func(t *testing.T) {
+		t.Setenv("LOG_HANDLER", "console")
+		require.Equal(t, "console", newConfig().ApplyEnv().Handler)
+	})
+	t.Run("LOG_FILE", func(t *testing.T) {
+		t.Setenv("LOG_FILE", "/tmp/app.log")
+		c := newConfig()
+		file := c.File
+		c.ApplyEnv()
+		require.Equal(t, &log.LumberjackConfig{Filename: "/tmp/app.log", MaxSize: 10}, c.File)
+		require.Equal(t, "/var/log/app.log", file.Filename)
+
+		c = &log.Config{}
+		require.Equal(t, &log.LumberjackConfig{Filename: "/tmp/app.log"}, c.ApplyEnv().File)
+	})
+	t.Run("LOG_LEVEL_path", func(t *testing.T) {
+		t.Setenv("LOG_LEVEL_/http-req", "debug")
+		t.Setenv("LOG_LEVEL_db", "trace")
+		c := newConfig().ApplyEnv()
+		require.Equal(t, "info", c.Level)
+		require.Equal(t, &log.Config{Level: "debug"}, c.Named["/http-req"])
+		require.Equal(t, &log.Config{Level: "trace", Handler: "json"}, c.Named["/db"])
+	})
+	t.Run("SetDefault", func(t *testing.T) {
+		t.Setenv("LOG_LEVEL", "warn")
+		t.Setenv("LOG_LEVEL_/env/sub", "debug")
+		useEnv := true
+		log.SetDefault(&log.Config{
+			Level:   "info",
+			Handler: "discard",
+			UseEnv:  &useEnv,
+		})
+		defer log.SetDefault(log.NewConfig())
+		require.Equal(t, "warn", log.Root().Level())
+		require.Equal(t, "debug", log.Get("/env/sub").Level())
+	})
+}
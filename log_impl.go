@@ -3,20 +3,27 @@ package log
 import (
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
 	"sync"
-
-	"gopkg.in/natefinch/lumberjack.v2"
+	"time"
 
 	apex "github.com/eluv-io/apexlog-go"
 	"github.com/eluv-io/apexlog-go/handlers/discard"
 	"github.com/eluv-io/apexlog-go/handlers/json"
 	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go/handlers/audit"
+	"github.com/eluv-io/log-go/handlers/cloudevents"
+	"github.com/eluv-io/log-go/handlers/cloudwatch"
 	"github.com/eluv-io/log-go/handlers/console"
+	"github.com/eluv-io/log-go/handlers/deferred"
+	"github.com/eluv-io/log-go/handlers/gcp"
+	ejson "github.com/eluv-io/log-go/handlers/json"
 	"github.com/eluv-io/log-go/handlers/raw"
 	"github.com/eluv-io/log-go/handlers/text"
+	"github.com/eluv-io/utc-go"
 )
 
 var (
@@ -24,6 +31,8 @@ var (
 )
 
 func init() {
+	// timestamp entries with utc-go's clock, which can be mocked in tests
+	apex.Now = func() time.Time { return utc.Now().Time }
 	apex.SetHandler(json.New(os.Stdout))
 	rootLog = defaultLogRoot()
 }
@@ -40,6 +49,7 @@ func defaultLogRoot() *logRoot {
 }
 
 func newLogRoot(c *Config) *logRoot {
+	setCrashBuffer(c.CrashBuffer)
 	return &logRoot{
 		named:     make(map[string]*Log),
 		defConfig: c,
@@ -70,6 +80,9 @@ func (r *logRoot) sameConfig(c *Config) bool {
 func (r *logRoot) setDefault(c *Config) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if c.UseEnv != nil && *c.UseEnv {
+		c.ApplyEnv()
+	}
 	r.setDefaultNoLock(c)
 }
 
@@ -77,16 +90,172 @@ func (r *logRoot) setDefaultNoLock(c *Config) {
 	if r.sameConfig(c) {
 		return
 	}
+	if c.Strict != nil && *c.Strict {
+		if err := c.Validate(); err != nil {
+			r.def.get().Error("invalid log config - ignored", err)
+			return
+		}
+	}
+	r.applyConfigNoLock(c)
+}
+
+// forceReload closes all log files and re-creates all logs from the current
+// default configuration.
+func (r *logRoot) forceReload() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closeLogs()
+	r.applyConfigNoLock(r.defConfig)
+}
+
+// applyConfigNoLock creates the default log from the given config and updates
+// all named logs accordingly.
+func (r *logRoot) applyConfigNoLock(c *Config) {
+	var overrides map[string]apex.Level
+	if c.PreserveLevelOverrides != nil && *c.PreserveLevelOverrides {
+		overrides = r.levelOverrides()
+	}
+	var before map[string]*Config
+	logDiff := c.LogConfigDiff != nil && *c.LogConfigDiff
+	if logDiff {
+		before = r.effectiveConfigs()
+	}
+	setCrashBuffer(c.CrashBuffer)
+	closeCompressedStreams()
 	r.def = New(c)
 	r.defConfig = c
 	updateNamedLoggers(r.def, r.named)
+	r.applyLevelOverrides(overrides)
+	if logDiff {
+		changes := diffConfigs(before, r.effectiveConfigs())
+		if len(changes) > 0 {
+			r.def.get().Info("log config changed", "changes", changes)
+		}
+	}
+}
+
+// effectiveConfigs returns the current configs of all logs by path.
+func (r *logRoot) effectiveConfigs() map[string]*Config {
+	ret := make(map[string]*Config, len(r.named)+1)
+	ret["/"] = r.def.get().config
+	for path, log := range r.named {
+		ret[path] = log.get().config
+	}
+	return ret
+}
+
+// configChange describes the change of a single setting of a logger's config.
+type configChange struct {
+	Logger  string `json:"logger"`
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// diffConfigs returns the changes of level, handler and file between the
+// given configs, sorted by logger path.
+func diffConfigs(before, after map[string]*Config) []*configChange {
+	filename := func(c *Config) string {
+		if c.File == nil {
+			return ""
+		}
+		return c.File.Filename
+	}
+
+	paths := make([]string, 0, len(after))
+	for path := range after {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ret []*configChange
+	for _, path := range paths {
+		old, found := before[path]
+		if !found {
+			continue
+		}
+		cfg := after[path]
+		add := func(setting, o, n string) {
+			if o != n {
+				ret = append(ret, &configChange{Logger: path, Setting: setting, Old: o, New: n})
+			}
+		}
+		add("level", old.Level, cfg.Level)
+		add("handler", old.Handler, cfg.Handler)
+		add("file", filename(old), filename(cfg))
+	}
+	return ret
+}
+
+// setLevelNoLock sets the level of the given log and all named logs below it.
+// The level of the given log is marked as overridden, while the named logs
+// below it inherit the new level (and lose any previous override).
+func (r *logRoot) setLevelNoLock(l *Log, level apex.Level) {
+	logName := l.get().name
+	// the name may be empty, e.g. with the console handler
+	levelSource := "/"
+	for path, log := range r.named {
+		if log == l {
+			levelSource = path
+			break
+		}
+	}
+	setLevel := func(overridden bool) func(logCopy *logger) {
+		return func(logCopy *logger) {
+			logCopy.logger().Level = level
+			logCopy.config.Level = level.String()
+			logCopy.levelOverride = overridden
+			logCopy.levelSource = levelSource
+			logCopy.levelExplicit = true
+		}
+	}
+
+	for name, log := range r.named {
+		oldLogger := log.get()
+		if strings.HasPrefix(name, logName) {
+			newLogger := oldLogger.copy(setLevel(false))
+			log.set(newLogger)
+		}
+	}
+	l.set(l.get().copy(setLevel(true)))
+}
+
+// levelOverrides returns the levels of all logs (including the default log
+// with an empty path) whose level was set programmatically.
+func (r *logRoot) levelOverrides() map[string]apex.Level {
+	ret := make(map[string]apex.Level)
+	if lg := r.def.get(); lg.levelOverride {
+		ret[""] = lg.logger().Level
+	}
+	for path, log := range r.named {
+		if lg := log.get(); lg.levelOverride {
+			ret[path] = lg.logger().Level
+		}
+	}
+	return ret
+}
+
+// applyLevelOverrides re-applies the given level overrides from the top of the
+// hierarchy downwards.
+func (r *logRoot) applyLevelOverrides(overrides map[string]apex.Level) {
+	paths := make([]string, 0, len(overrides))
+	for path := range overrides {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		log := r.def
+		if path != "" {
+			log = r.named[path]
+		}
+		r.setLevelNoLock(log, overrides[path])
+	}
 }
 
 func (r *logRoot) closeLogs() {
 	closeLog := func(l *Log) {
-		if l.get().lumberjack != nil {
-			_ = l.get().lumberjack.Close()
-		}
+		_ = l.get().close()
 	}
 	for _, l := range r.named {
 		closeLog(l)
@@ -124,6 +293,8 @@ func (r *logRoot) Get(path string) *Log {
 	log = r.def
 	var logPath = "/"    // the path corresponding to the log instance in "log"
 	conf := *r.defConfig // copy defConfig
+	// the path and explicitness of the level source
+	levelSource, levelExplicit := r.def.LevelSource()
 	idx := 0
 	for idx < len(path) {
 		idx++ // skip the "current" separator
@@ -138,14 +309,19 @@ func (r *logRoot) Get(path string) *Log {
 			log = l
 			logPath = p
 			conf = *l.get().config
+			levelSource, levelExplicit = l.LevelSource()
 		}
 		if c, configFound := r.defConfig.Named[p]; configFound {
 			if !logFound {
 				// there is a config at this level, but no log yet.
 				// copy the merged configuration and create a new log from it
 				mergeConfig(c, &conf)
+				if c.Level != "" {
+					levelSource, levelExplicit = p, true
+				}
 				cc := conf
-				log = newLog(&cc, defaultFields(&cc, p), log)
+				log = newNamedLog(&cc, p, log)
+				log.setLevelSource(levelSource, levelExplicit)
 				r.named[p] = log
 				logPath = p
 			}
@@ -156,7 +332,8 @@ func (r *logRoot) Get(path string) *Log {
 	}
 
 	cc := conf
-	log = newLog(&cc, defaultFields(&cc, path), log)
+	log = newNamedLog(&cc, path, log)
+	log.setLevelSource(levelSource, levelExplicit)
 	r.named[path] = log
 	return log
 }
@@ -170,6 +347,7 @@ func updateNamedLoggers(root *Log, named map[string]*Log) {
 		rootConfig := root.get().config
 		conf := *(rootConfig) // copy defConfig
 		parent := root
+		levelSource, levelExplicit := root.LevelSource()
 		idx := 0
 		for idx < len(path) {
 			idx++ // skip the "current" separator
@@ -181,6 +359,9 @@ func updateNamedLoggers(root *Log, named map[string]*Log) {
 			p := path[:idx]
 			if cfg, found := rootConfig.Named[p]; found {
 				mergeConfig(cfg, &conf)
+				if cfg.Level != "" {
+					levelSource, levelExplicit = p, true
+				}
 			}
 			if p != path {
 				if l, found := named[p]; found {
@@ -188,27 +369,40 @@ func updateNamedLoggers(root *Log, named map[string]*Log) {
 				}
 			}
 		}
-		nl := newLog(&conf, defaultFields(&conf, path), parent)
+		nl := newNamedLog(&conf, path, parent)
+		nl.setLevelSource(levelSource, levelExplicit)
 		// replace all members of current log instance with newly created ones
 		log.updateFrom(nl)
 	}
 }
 
-// newLog creates a new Log wrapper from the given configuration and additional
-// log fields
-func newLog(c *Config, fields *apex.Fields, parent *Log) *Log {
-	var ljack *lumberjack.Logger
-	var writer io.Writer = os.Stdout
+// newNamedLog creates a new Log wrapper for the logger with the given path.
+func newNamedLog(c *Config, path string, parent *Log) *Log {
+	ret := newLog(c, defaultFields(c, path), parent)
+	keepRecentEntries(ret, c, path)
+	return ret
+}
 
-	level, err := apex.ParseLevel(c.Level)
-	if err != nil {
-		level = apex.InfoLevel
+// keepRecentEntries wraps the handler of the given log in order to keep its
+// recent entries if configured with RecentEntries.
+func keepRecentEntries(lg *Log, c *Config, path string) {
+	if c.RecentEntries > 0 {
+		al := lg.get().logger()
+		al.Handler = newRecentHandler(al.Handler, path, c.RecentEntries)
 	}
+}
 
-	file := c.File
-	if file != nil && file.Filename == "" {
-		// no filename is equivalent to logging to stdout
-		file = nil
+// newLog creates a new Log wrapper from the given configuration and additional
+// log fields
+func newLog(c *Config, fields *apex.Fields, parent *Log) *Log {
+	var file *sharedFile
+	var gzw *gzipWriter
+	writer := outputWriter(c)
+
+	fc := c.File
+	if fc != nil && fc.Filename == "" && !fc.UseTempFile {
+		// no filename is equivalent to logging to the configured output
+		fc = nil
 	}
 
 	var handler apex.Handler
@@ -217,55 +411,225 @@ func newLog(c *Config, fields *apex.Fields, parent *Log) *Log {
 		par = parent.get()
 	}
 
-	if par != nil && par.config.Handler == c.Handler && reflect.DeepEqual(par.config.File, file) {
+	if par != nil && sameHandlerConfig(par.config, c, fc) {
 		// re-use the parent's handler if of same type
-		handler = par.logger().Handler
+		handler = par.handler()
 	} else {
 		metrics().InstanceCreated()
+		if fc != nil {
+			if err := os.MkdirAll(filepath.Dir(fc.path()), 0755); err != nil {
+				// fall back to the configured output rather than losing all entries
+				warnFileFallback(fc.path(), outputName(c), err)
+			} else {
+				file = openSharedFile(fc)
+				writer = file
+			}
+		}
+		if c.CompressStream != nil && *c.CompressStream {
+			if file != nil {
+				gzw = file.compressed()
+			} else {
+				gzw = compressedStream(writer)
+			}
+			writer = gzw
+		}
+		handler = newHandler(c, writer)
 		if file != nil {
-			ljack = NewLumberjackLogger(file)
-			writer = ljack
-			metrics().FileCreated()
+			file.setBanner(bannerFunc(c))
 		}
-		switch c.Handler {
-		case "text":
-			handler = text.New(writer)
-		case "raw":
-			handler = raw.New(writer)
-		case "console":
-			handler = console.New(writer)
-		case "discard":
-			handler = discard.Default
-		case "memory":
-			handler = memory.New()
-		case "json":
-			fallthrough
-		default:
-			handler = json.New(writer)
+		handler = deferHandler(c, handler)
+	}
+
+	ret := newLogWithHandler(c, fields, handler)
+	lg := ret.get()
+	lg.file = file
+	lg.gzip = gzw
+	return ret
+}
+
+// deferHandler returns the given handler wrapped in a deferred handler if
+// configured with DeferUntil.
+func deferHandler(c *Config, handler apex.Handler) apex.Handler {
+	if c.DeferUntil != "" {
+		if trigger, err := apex.ParseLevel(c.DeferUntil); err == nil {
+			return deferred.New(handler, trigger)
 		}
 	}
+	return handler
+}
+
+// newLogWithHandler creates a new Log wrapper from the given configuration,
+// additional log fields and handler.
+func newLogWithHandler(c *Config, fields *apex.Fields, handler apex.Handler) *Log {
+	level, err := apex.ParseLevel(c.Level)
+	if err != nil {
+		level = apex.InfoLevel
+	}
 
+	name := ""
+	if fields != nil {
+		name, _ = fields.Get("logger").(string)
+	}
 	apexLogger := &apex.Logger{
-		Handler: handler,
+		Handler: &notifyHandler{Handler: handler, logger: name},
 		Level:   level,
 	}
-	name := ""
 	var log apex.Interface = apexLogger
 	if fields != nil {
 		log = apexLogger.WithFields(fields)
-		name, _ = fields.Get("logger").(string)
+	}
+	var callerLevel *apex.Level
+	if c.CallerMinLevel != "" {
+		if lvl, err := apex.ParseLevel(c.CallerMinLevel); err == nil {
+			callerLevel = &lvl
+		}
 	}
 	ret := &Log{}
 	ret.lw.Store(&logger{
-		log:        log,
-		name:       name,
-		config:     c,
-		lumberjack: ljack,
+		log:         log,
+		name:        name,
+		config:      c,
+		callerLevel: callerLevel,
 	})
 	return ret
 }
 
+// outputWriter returns the writer configured with Output for logs without file.
+func outputWriter(c *Config) io.Writer {
+	if outputName(c) == "stderr" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// outputName returns the name of the output configured with Output.
+func outputName(c *Config) string {
+	if c.Output == "stderr" {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// newHandler creates the handler configured in c writing to the given writer.
+func newHandler(c *Config, writer io.Writer) apex.Handler {
+	if c.handler != nil {
+		return c.handler
+	}
+	if handler, ok := registeredHandler(c.Handler, writer); ok {
+		return handler
+	}
+	var handler apex.Handler
+	switch c.Handler {
+	case "text":
+		th := text.New(writer).WithFieldSeparator(c.TextFieldSeparator)
+		if c.TextMessageWidth != 0 {
+			th.WithMessageWidth(c.TextMessageWidth)
+		}
+		handler = th
+	case "raw":
+		handler = raw.New(writer).WithRawField(c.RawField)
+	case "console":
+		ch := console.New(writer)
+		if c.ConsoleStart != nil {
+			ch.WithStart(*c.ConsoleStart)
+		}
+		handler = ch
+	case "discard":
+		handler = discard.Default
+	case "memory":
+		handler = memory.New()
+	case "json-pretty":
+		handler = ejson.New(writer).WithIndent("", "  ")
+	case "json-ordered":
+		handler = ejson.New(writer).WithFieldArray(true)
+	case "json-sorted":
+		handler = ejson.New(writer).WithSortedFields(true)
+	case "gcp":
+		handler = gcp.New(writer)
+	case "cloudwatch":
+		handler = cloudwatch.New(writer)
+	case "audit":
+		ah := audit.New(writer)
+		if file, ok := writer.(*sharedFile); ok {
+			ah.WithChain(file.auditChain())
+		}
+		handler = ah
+	case "cloudevents":
+		handler = cloudevents.New(writer)
+	case "json":
+		handler = json.New(writer)
+	default:
+		warnUnknownHandler(c.Handler)
+		handler = json.New(writer)
+	}
+	return handler
+}
+
+// sameHandlerConfig returns true if a log with the given config c and file can
+// re-use the handler of the parent log with config par.
+func sameHandlerConfig(par, c *Config, file *LumberjackConfig) bool {
+	return par.Handler == c.Handler &&
+		reflect.DeepEqual(par.File, file) &&
+		outputWriter(par) == outputWriter(c) &&
+		par.TextMessageWidth == c.TextMessageWidth &&
+		par.TextFieldSeparator == c.TextFieldSeparator &&
+		par.RawField == c.RawField &&
+		par.DeferUntil == c.DeferUntil &&
+		reflect.DeepEqual(par.CompressStream, c.CompressStream) &&
+		reflect.DeepEqual(par.ConsoleStart, c.ConsoleStart)
+}
+
+// fileFallbacks records the log files for which a fallback warning was logged.
+var fileFallbacks sync.Map
+
+// warnFileFallback reports that the directory of the given log file cannot be
+// created and the log falls back to the configured output. The fallback is
+// counted if the global Metrics instance implements FileMetrics. The warning is
+// logged only once per file through the default log.
+func warnFileFallback(filename, output string, err error) {
+	if m, ok := metrics().(FileMetrics); ok {
+		m.FileFallback(filename)
+	}
+	if _, logged := fileFallbacks.LoadOrStore(filename, true); logged {
+		return
+	}
+	r := getLogRoot()
+	if r == nil || r.def == nil {
+		// still initializing
+		return
+	}
+	r.def.get().Warn("cannot create log directory - logging to "+output, err, "file", filename)
+}
+
+// unknownHandlers records the unknown handler names for which a warning was
+// logged.
+var unknownHandlers sync.Map
+
+// warnUnknownHandler logs a warning through the default log if the given
+// handler name is not empty and unknown. The warning is logged only once per
+// handler name.
+func warnUnknownHandler(name string) {
+	if name == "" || isKnownHandler(name) {
+		return
+	}
+	if _, logged := unknownHandlers.LoadOrStore(name, true); logged {
+		return
+	}
+	r := getLogRoot()
+	if r == nil || r.def == nil {
+		// still initializing
+		return
+	}
+	r.def.get().Warn("unknown log handler - using json", "handler", name, "known", HandlerNames())
+}
+
 func defaultFields(c *Config, path string) *apex.Fields {
+	if path == "/" && c.RootLoggerField != nil {
+		if *c.RootLoggerField {
+			return &apex.Fields{{Name: "logger", Value: path}}
+		}
+		return &apex.Fields{}
+	}
 	switch c.Handler {
 	case "console":
 		return &apex.Fields{}
@@ -289,13 +653,82 @@ func mergeConfig(c *Config, target *Config) {
 	if c.File != nil {
 		target.File = c.File
 	}
+	if c.Output != "" {
+		target.Output = c.Output
+	}
 	if c.GoRoutineID != nil {
 		b := *c.GoRoutineID
 		target.GoRoutineID = &b
 	}
+	if c.GoRoutineIDKey != "" {
+		target.GoRoutineIDKey = c.GoRoutineIDKey
+	}
+	if c.IncludeHostname != nil {
+		target.IncludeHostname = c.IncludeHostname
+	}
+	if c.IncludeVersion != nil {
+		target.IncludeVersion = c.IncludeVersion
+	}
+	if c.IncludeGoroutineCount != nil {
+		target.IncludeGoroutineCount = c.IncludeGoroutineCount
+	}
+	if c.ConsoleStart != nil {
+		target.ConsoleStart = c.ConsoleStart
+	}
+	if c.Prefix != "" {
+		target.Prefix = c.Prefix
+	}
+	if c.TextMessageWidth != 0 {
+		target.TextMessageWidth = c.TextMessageWidth
+	}
+	if c.TextFieldSeparator != "" {
+		target.TextFieldSeparator = c.TextFieldSeparator
+	}
+	if c.OmitEmptyFields != nil {
+		target.OmitEmptyFields = c.OmitEmptyFields
+	}
+	if c.MaxFields != 0 {
+		target.MaxFields = c.MaxFields
+	}
+	if c.StrictFields != nil {
+		target.StrictFields = c.StrictFields
+	}
+	if c.Sequence != nil {
+		target.Sequence = c.Sequence
+	}
+	if c.ExtractErrorKind != nil {
+		target.ExtractErrorKind = c.ExtractErrorKind
+	}
+	if c.RawField != "" {
+		target.RawField = c.RawField
+	}
+	if c.DeferUntil != "" {
+		target.DeferUntil = c.DeferUntil
+	}
+	if c.Banner != nil {
+		target.Banner = c.Banner
+	}
+	if c.CompressStream != nil {
+		target.CompressStream = c.CompressStream
+	}
+	if c.EnforceUTF8 != nil {
+		target.EnforceUTF8 = c.EnforceUTF8
+	}
+	if c.FieldTypes != nil {
+		target.FieldTypes = c.FieldTypes
+	}
+	if c.BytesFormat != "" {
+		target.BytesFormat = c.BytesFormat
+	}
 	if c.Caller != nil {
 		target.Caller = c.Caller
 	}
+	if c.CallerMinLevel != "" {
+		target.CallerMinLevel = c.CallerMinLevel
+	}
+	if c.RecentEntries != 0 {
+		target.RecentEntries = c.RecentEntries
+	}
 }
 
 func sortedKeys(m map[string]*Log) []string {
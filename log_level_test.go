@@ -1,11 +1,13 @@
 package log_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/eluv-io/apexlog-go/handlers/memory"
 	"github.com/eluv-io/log-go"
 )
 
@@ -187,3 +189,138 @@ func TestLevelPropagation(t *testing.T) {
 	ep3 := log.Get("/api/ep3")
 	assertLevelTrace(t, ep3) // inherited from /api (which was set to trace above)
 }
+
+func TestLevelOverrideSetDefault(t *testing.T) {
+	// reset any overrides of previous tests
+	log.SetDefault(log.NewConfig())
+
+	preserve := true
+	newConfig := func(dbLevel string) *log.Config {
+		return &log.Config{
+			Level:                  "info",
+			Handler:                "discard",
+			PreserveLevelOverrides: &preserve,
+			Named: map[string]*log.Config{
+				"/api": {
+					Level: "info",
+				},
+				"/db": {
+					Level: dbLevel,
+				},
+			},
+		}
+	}
+	log.SetDefault(newConfig("info"))
+
+	api := log.Get("/api")
+	ep := log.Get("/api/ep")
+	db := log.Get("/db")
+
+	api.SetDebug()
+	assertLevelDebug(t, api)
+	assertLevelDebug(t, ep)
+	assertLevelInfo(t, db)
+
+	// unrelated change: runtime override of /api is preserved
+	log.SetDefault(newConfig("warn"))
+	assertLevelInfo(t, log.Root())
+	assertLevelDebug(t, api)
+	assertLevelDebug(t, ep)
+	assertLevelWarn(t, db)
+
+	// override of the root logger is preserved as well
+	log.Root().SetError()
+	log.SetDefault(newConfig("info"))
+	assertLevelError(t, log.Root())
+	assertLevelError(t, api)
+	assertLevelError(t, ep)
+	assertLevelError(t, db)
+
+	// overrides are reset if not preserved
+	preserve = false
+	log.SetDefault(newConfig("warn"))
+	assertLevelInfo(t, log.Root())
+	assertLevelInfo(t, api)
+	assertLevelInfo(t, ep)
+	assertLevelWarn(t, db)
+}
+
+func TestLogConfigDiff(t *testing.T) {
+	log.SetDefault(log.NewConfig())
+
+	logDiff := true
+	newConfig := func(dbLevel string) *log.Config {
+		return &log.Config{
+			Level:         "info",
+			Handler:       "memory",
+			LogConfigDiff: &logDiff,
+			Named: map[string]*log.Config{
+				"/api": {
+					Level: "info",
+				},
+				"/db": {
+					Level: dbLevel,
+				},
+			},
+		}
+	}
+	log.SetDefault(newConfig("info"))
+	log.Get("/api")
+	log.Get("/db")
+	log.Get("/db/sql")
+
+	log.SetDefault(newConfig("warn"))
+	defer log.SetDefault(log.NewConfig())
+
+	handler := log.Root().Handler().(*memory.Handler)
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "log config changed", handler.Entries[0].Message)
+
+	changes, err := json.Marshal(handler.Entries[0].Fields.Get("changes"))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"logger":"/db","setting":"level","old":"info","new":"warn"},
+		{"logger":"/db/sql","setting":"level","old":"info","new":"warn"}
+	]`, string(changes))
+}
+
+func TestLevelSource(t *testing.T) {
+	log.SetDefault(&log.Config{
+		Handler: "discard",
+		Named: map[string]*log.Config{
+			"/src/a":       {Level: "debug"},
+			"/src/a/b/c":   {Handler: "text"},
+			"/src/a/b/c/d": {Level: "warn"},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+
+	assertSource := func(path, wantPath string, wantExplicit bool) {
+		src, explicit := log.Get(path).LevelSource()
+		require.Equal(t, wantPath, src, path)
+		require.Equal(t, wantExplicit, explicit, path)
+	}
+
+	assertSource("/src/a/b", "/src/a", true)
+	assertSource("/src/a", "/src/a", true)
+	assertSource("/src/a/b/c", "/src/a", true)
+	assertSource("/src/a/b/c/d", "/src/a/b/c/d", true)
+	assertSource("/src/x", "/", false)
+	assertSource("/", "/", false)
+
+	// programmatic levels
+	log.Get("/src/a/b").SetInfo()
+	assertSource("/src/a/b", "/src/a/b", true)
+	assertSource("/src/a/b/c", "/src/a/b", true)
+
+	// re-evaluated with a new config
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "discard",
+		Named: map[string]*log.Config{
+			"/src/a/b": {Level: "debug"},
+		},
+	})
+	assertSource("/src/a/b/c", "/src/a/b", true)
+	assertSource("/src/a", "/", true)
+}
@@ -3,10 +3,13 @@ package log_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
@@ -15,9 +18,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	apex "github.com/eluv-io/apexlog-go"
 	ljson "github.com/eluv-io/apexlog-go/handlers/json"
 	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/errors-go"
 	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/audit"
+	ejson "github.com/eluv-io/log-go/handlers/json"
+	"github.com/eluv-io/log-go/handlers/text"
+	"github.com/eluv-io/log-go/logtest"
+	"github.com/eluv-io/utc-go"
 )
 
 func TestLoggingToFile(t *testing.T) {
@@ -107,6 +117,32 @@ func TestLoggingToConsole(t *testing.T) {
 	require.False(t, strings.Contains(s, "logger"))
 }
 
+func TestOutput(t *testing.T) {
+	dir := t.TempDir()
+	count := 0
+	capture := func(stream **os.File, output string) string {
+		count++
+		temp, err := os.Create(filepath.Join(dir, fmt.Sprintf("stream%d", count)))
+		require.NoError(t, err)
+		old := *stream
+		*stream = temp
+		defer func() { *stream = old }()
+
+		lg := log.New(&log.Config{Level: "info", Handler: "text", Output: output})
+		lg.Info("message on " + output)
+		require.NoError(t, temp.Close())
+
+		bb, err := os.ReadFile(temp.Name())
+		require.NoError(t, err)
+		return string(bb)
+	}
+
+	require.Contains(t, capture(&os.Stderr, "stderr"), "message on stderr")
+	require.Empty(t, capture(&os.Stdout, "stderr"))
+	require.Contains(t, capture(&os.Stdout, "stdout"), "message on stdout")
+	require.Contains(t, capture(&os.Stdout, ""), "message on ")
+}
+
 func TestAll(t *testing.T) {
 	logger := log.New(
 		&log.Config{
@@ -122,6 +158,954 @@ func TestAll(t *testing.T) {
 	// doTest(t, log.Fatal)
 }
 
+func TestPanic(t *testing.T) {
+	m := &metrics{}
+	log.SetMetrics(m)
+	defer log.SetMetrics(nil)
+
+	logger := log.New(
+		&log.Config{
+			Handler: "memory",
+			Level:   "debug",
+		})
+	handler := logger.Handler().(*memory.Handler)
+
+	var recovered interface{}
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+		logger.Panic("unexpected state", "state", "broken")
+	}()
+
+	require.Equal(t, "unexpected state", recovered)
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "unexpected state", handler.Entries[0].Message)
+	require.Equal(t, "error", handler.Entries[0].Level.String())
+	require.Equal(t, "broken", handler.Entries[0].Fields.Get("state"))
+	require.Equal(t, 1, m.error)
+
+	// compressed output is flushed to the file before panicking
+	f := filepath.Join(t.TempDir(), "panic.log.gz")
+	compress := true
+	logger = log.New(&log.Config{
+		Level:          "info",
+		Handler:        "text",
+		File:           &log.LumberjackConfig{Filename: f},
+		CompressStream: &compress,
+	})
+	defer func() { _ = logger.Close() }()
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+		logger.WithMap(map[string]interface{}{"key": "value"}).Panic("flushed", "state", "broken")
+	}()
+	require.Equal(t, "flushed", recovered)
+	fh, err := os.Open(f)
+	require.NoError(t, err)
+	defer func() { _ = fh.Close() }()
+	zr, err := gzip.NewReader(fh)
+	require.NoError(t, err)
+	bts, _ := io.ReadAll(zr) // the stream is flushed, but not terminated
+	require.Contains(t, string(bts), "flushed")
+}
+
+func TestForceReload(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "test.log")
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: f},
+	})
+	defer log.SetDefault(log.NewConfig())
+	defer log.CloseLogFiles()
+
+	lg := log.Get("/reload")
+	lg.Info("before removal")
+	_, err := os.Stat(f)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(f))
+	log.ForceReload()
+
+	lg.Info("after reload")
+	bts, err := os.ReadFile(f)
+	require.NoError(t, err)
+	require.Contains(t, string(bts), "after reload")
+	require.NotContains(t, string(bts), "before removal")
+}
+
+func TestAuditAppend(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		lg := log.New(&log.Config{
+			Level:   "info",
+			Handler: "audit",
+			File:    &log.LumberjackConfig{Filename: f},
+		})
+		lg.Info("entry", "run", i)
+		require.NoError(t, lg.Close())
+	}
+
+	bts, err := os.ReadFile(f)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(bts), "\n"))
+	require.NoError(t, audit.Verify(bytes.NewReader(bts)))
+
+	// a single chain for all handlers writing to the file
+	f = filepath.Join(t.TempDir(), "shared.log")
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "audit",
+		File:    &log.LumberjackConfig{Filename: f},
+		Named: map[string]*log.Config{
+			"/audit/deferred": {DeferUntil: "warn"},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+	for i := 0; i < 3; i++ {
+		log.Info("entry", "run", i)
+		log.Get("/audit/deferred").Warn("named entry", "run", i)
+	}
+	log.CloseLogFiles()
+	bts, err = os.ReadFile(f)
+	require.NoError(t, err)
+	require.Equal(t, 6, strings.Count(string(bts), "\n"))
+	require.NoError(t, audit.Verify(bytes.NewReader(bts)))
+
+	// no chain continued when not writing to the file
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	stale := `{"prev_hash":"","hash":"deadbeef"}` + "\n"
+	lj := filepath.Join(dir, filepath.Base(os.Args[0])+"-lumberjack.log")
+	require.NoError(t, os.WriteFile(lj, []byte(stale), 0644))
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	require.NoError(t, err)
+	prev := os.Stdout
+	os.Stdout = stdout
+	lg := log.New(&log.Config{Level: "info", Handler: "audit", File: log.Stdout})
+	lg.Info("entry")
+	os.Stdout = prev
+	require.NoError(t, stdout.Close())
+	bts, err = os.ReadFile(stdout.Name())
+	require.NoError(t, err)
+	require.NoError(t, audit.Verify(bytes.NewReader(bts)))
+}
+
+func TestIncludeHostname(t *testing.T) {
+	log.SetHostname("node-1")
+	defer log.SetHostname("")
+
+	include := true
+	logger := log.New(
+		&log.Config{
+			Handler:         "memory",
+			Level:           "debug",
+			IncludeHostname: &include,
+		})
+	handler := logger.Handler().(*memory.Handler)
+
+	logger.Info("with host", "key", "value")
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "node-1", handler.Entries[0].Fields.Get("host"))
+	require.Equal(t, []string{"logger", "host", "key"}, fieldNames(handler.Entries[0].Fields))
+
+	log.SetHostname("")
+	host, err := os.Hostname()
+	require.NoError(t, err)
+	logger.Info("with os host")
+	require.Equal(t, host, handler.Entries[1].Fields.Get("host"))
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "test.log")
+	lg := log.New(&log.Config{
+		Level:   "info",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: f},
+	})
+
+	lg.Info("before close")
+	require.NoError(t, lg.Close())
+	require.NoError(t, lg.Close())
+
+	// the file is released: entries go to a new file after a move
+	moved := filepath.Join(dir, "moved.log")
+	require.NoError(t, os.Rename(f, moved))
+	lg.Info("after close")
+	require.NoError(t, lg.Close())
+
+	bts, err := os.ReadFile(f)
+	require.NoError(t, err)
+	require.Contains(t, string(bts), "after close")
+	bts, err = os.ReadFile(moved)
+	require.NoError(t, err)
+	require.NotContains(t, string(bts), "after close")
+
+	require.NoError(t, log.New(&log.Config{Handler: "discard"}).Close())
+}
+
+func TestVersionInfo(t *testing.T) {
+	log.SetVersionInfo("v1.2.3", "abc1234")
+	defer log.SetVersionInfo("", "")
+
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "memory", IncludeVersion: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+	lg.Info("message", "key", "value")
+	require.Equal(t, []string{"version", "commit", "key"}, fieldNames(handler.Entries[0].Fields))
+	require.Equal(t, "v1.2.3", handler.Entries[0].Fields.Get("version"))
+	require.Equal(t, "abc1234", handler.Entries[0].Fields.Get("commit"))
+
+	// not included by default
+	lg, handler = newMemoryLog("info")
+	lg.Info("message")
+	require.Nil(t, handler.Entries[0].Fields.Get("version"))
+
+	// but reported in the banner
+	fname := filepath.Join(t.TempDir(), "version.log")
+	lg = log.New(&log.Config{
+		Level:   "info",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: fname},
+		Banner:  &trueVal,
+	})
+	lg.Info("message")
+	require.NoError(t, lg.Close())
+	bb, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "version=v1.2.3")
+	require.Contains(t, string(bb), "commit=abc1234")
+}
+
+func TestGoroutineCount(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "memory", IncludeGoroutineCount: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+	lg.Info("message", "key", "value")
+	require.Equal(t, []string{"goroutines", "key"}, fieldNames(handler.Entries[0].Fields))
+	count, ok := handler.Entries[0].Fields.Get("goroutines").(int)
+	require.True(t, ok)
+	require.Greater(t, count, 0)
+
+	// not included by default
+	lg, handler = newMemoryLog("info")
+	lg.Info("message")
+	require.Nil(t, handler.Entries[0].Fields.Get("goroutines"))
+}
+
+func TestEnableOnlyGID(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "debug", Handler: "memory", GoRoutineID: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+
+	// determine the gid of this goroutine
+	lg.Info("gid")
+	gid := handler.Entries[0].Fields.Get("gid").(int64)
+	handler.Entries = nil
+
+	log.EnableOnlyGID(gid)
+	defer log.EnableOnlyGID()
+
+	lg.Debug("allowed")
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		// level checks are not affected
+		assert.True(t, lg.IsDebug())
+		lg.Debug("suppressed")
+		lg.Warn("suppressed")
+		lg.Error("other error")
+		lg.Always("info", "always")
+	}()
+	<-done
+
+	require.Equal(t, 3, len(handler.Entries))
+	require.Equal(t, "allowed", handler.Entries[0].Message)
+	require.Equal(t, "other error", handler.Entries[1].Message)
+	require.Equal(t, "always", handler.Entries[2].Message)
+
+	// restriction removed
+	log.EnableOnlyGID()
+	handler.Entries = nil
+	done = make(chan bool)
+	go func() {
+		defer close(done)
+		lg.Info("unrestricted")
+	}()
+	<-done
+	require.Equal(t, 1, len(handler.Entries))
+}
+
+func TestLogFileDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	// missing directories are created
+	fname := filepath.Join(dir, "a", "b", "c", "test.log")
+	lg := log.New(&log.Config{Level: "info", File: &log.LumberjackConfig{Filename: fname}})
+	lg.Info("nested message")
+	require.NoError(t, lg.Close())
+	fi, err := os.Stat(filepath.Join(dir, "a", "b", "c"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+	bb, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "nested message")
+
+	// falls back to stdout if the directory cannot be created
+	m := &fileMetrics{}
+	log.SetMetrics(m)
+	defer log.SetMetrics(nil)
+	blocker := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0644))
+	fname = filepath.Join(blocker, "sub", "test.log")
+	lg = log.New(&log.Config{Level: "info", Handler: "discard", File: &log.LumberjackConfig{Filename: fname}})
+	lg.Info("fallback message")
+	require.NoError(t, lg.Close())
+	require.Equal(t, []string{fname}, m.fallbacks)
+	require.Equal(t, 0, m.files)
+}
+
+func TestEmptyFilename(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	tempFile := filepath.Join(dir, filepath.Base(os.Args[0])+"-lumberjack.log")
+
+	// an empty filename logs to stdout by default
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	require.NoError(t, err)
+	old := os.Stdout
+	os.Stdout = stdout
+	lg := log.New(&log.Config{Level: "info", Handler: "text", File: &log.LumberjackConfig{}})
+	os.Stdout = old
+	lg.Info("stdout message")
+	require.NoError(t, lg.Close())
+	require.NoError(t, stdout.Close())
+	bb, err := os.ReadFile(stdout.Name())
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "stdout message")
+	_, err = os.Stat(tempFile)
+	require.True(t, os.IsNotExist(err))
+
+	// or to lumberjack's default file in the temp dir if requested
+	lg = log.New(&log.Config{Level: "info", Handler: "text", File: &log.LumberjackConfig{UseTempFile: true}})
+	lg.Info("temp file message")
+	require.NoError(t, lg.Close())
+	bb, err = os.ReadFile(tempFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "temp file message")
+}
+
+type fileMetrics struct {
+	metrics
+	fallbacks []string
+}
+
+func (m *fileMetrics) FileFallback(filename string) {
+	m.fallbacks = append(m.fallbacks, filename)
+}
+
+func TestBanner(t *testing.T) {
+	dir := t.TempDir()
+	trueVal := true
+
+	for _, handler := range []string{"json", "text"} {
+		fname := filepath.Join(dir, handler+".log")
+		lg := log.New(&log.Config{
+			Level:   "info",
+			Handler: handler,
+			File:    &log.LumberjackConfig{Filename: fname},
+			Banner:  &trueVal,
+		})
+		lg.Info("first message")
+		require.NoError(t, lg.Close())
+
+		bb, err := os.ReadFile(fname)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(bb)), "\n")
+		require.Equal(t, 2, len(lines), handler)
+		require.Contains(t, lines[0], "log started")
+		require.Contains(t, lines[0], fmt.Sprint(os.Getpid()))
+		require.Contains(t, lines[1], "first message")
+		if handler == "json" {
+			var banner struct {
+				Fields map[string]interface{} `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &banner))
+			require.Equal(t, filepath.Base(os.Args[0]), banner.Fields["process"])
+			require.Equal(t, float64(os.Getpid()), banner.Fields["pid"])
+			require.Contains(t, banner.Fields, "start")
+		} else {
+			require.Contains(t, lines[0], "process="+filepath.Base(os.Args[0]))
+		}
+	}
+
+	// a single banner per file, regardless of the number of handlers
+	fname := filepath.Join(dir, "shared.log")
+	conf := &log.Config{
+		Level:   "info",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: fname},
+		Banner:  &trueVal,
+		Named: map[string]*log.Config{
+			"/banner/deferred": {DeferUntil: "warn"},
+		},
+	}
+	log.SetDefault(conf)
+	defer log.SetDefault(log.NewConfig())
+	log.Get("/banner/deferred").Warn("deferred message")
+	log.SetDefault(conf)
+	log.Info("first message")
+	log.CloseLogFiles()
+	bb, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(bb), "log started"))
+	require.Contains(t, strings.SplitN(string(bb), "\n", 2)[0], "log started")
+
+	// no banner when appending to an existing file
+	log.SetDefault(conf)
+	log.Info("second message")
+	log.CloseLogFiles()
+	bb, err = os.ReadFile(fname)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(bb), "log started"))
+
+	// a banner at the start of each rotated file
+	rdir := filepath.Join(dir, "rotated")
+	lg := log.New(&log.Config{
+		Level:   "info",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: filepath.Join(rdir, "rotated.log"), MaxSize: 1},
+		Banner:  &trueVal,
+	})
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 2500; i++ {
+		lg.Info("message", "payload", payload)
+	}
+	require.NoError(t, lg.Close())
+	files, err := os.ReadDir(rdir)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(files))
+	for _, file := range files {
+		bb, err = os.ReadFile(filepath.Join(rdir, file.Name()))
+		require.NoError(t, err)
+		require.Contains(t, strings.SplitN(string(bb), "\n", 2)[0], "log started", file.Name())
+		require.Equal(t, 1, strings.Count(string(bb), "log started"), file.Name())
+	}
+
+	// no banner by default
+	fname = filepath.Join(dir, "default.log")
+	lg = log.New(&log.Config{Level: "info", File: &log.LumberjackConfig{Filename: fname}})
+	lg.Info("first message")
+	require.NoError(t, lg.Close())
+	bb, err = os.ReadFile(fname)
+	require.NoError(t, err)
+	require.NotContains(t, string(bb), "log started")
+}
+
+func TestCompressStream(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "test.log.gz")
+	compress := true
+	lg := log.New(&log.Config{
+		Level:          "info",
+		Handler:        "json",
+		File:           &log.LumberjackConfig{Filename: f},
+		CompressStream: &compress,
+	})
+
+	// complete is false if the gzip stream is flushed, but not terminated
+	decompress := func(complete bool) string {
+		fh, err := os.Open(f)
+		require.NoError(t, err)
+		defer func() { _ = fh.Close() }()
+		zr, err := gzip.NewReader(fh)
+		require.NoError(t, err)
+		bts, err := io.ReadAll(zr)
+		if complete || err != io.ErrUnexpectedEOF {
+			require.NoError(t, err)
+		}
+		return string(bts)
+	}
+
+	lg.Info("first message", "key", "value")
+	lg.Info("second message")
+	require.NoError(t, lg.Sync())
+	require.Contains(t, decompress(false), "second message")
+
+	require.NoError(t, lg.Close())
+	require.NoError(t, lg.Close())
+
+	// re-opened after close, appending a new gzip member
+	lg.Info("third message")
+	require.NoError(t, lg.Close())
+
+	lines := strings.Split(strings.TrimSpace(decompress(true)), "\n")
+	require.Equal(t, 3, len(lines))
+	for i, msg := range []string{"first message", "second message", "third message"} {
+		line := struct {
+			Message string `json:"message"`
+		}{}
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &line))
+		require.Equal(t, msg, line.Message)
+	}
+}
+
+func TestCompressStdout(t *testing.T) {
+	temp, err := os.Create(filepath.Join(t.TempDir(), "stdout.gz"))
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = temp
+	defer func() {
+		os.Stdout = stdout
+		log.SetDefault(log.NewConfig())
+	}()
+
+	compress := true
+	conf := &log.Config{
+		Level:          "info",
+		Handler:        "json",
+		CompressStream: &compress,
+		Named: map[string]*log.Config{
+			"/compress/text": {Handler: "text"},
+		},
+	}
+	log.SetDefault(conf)
+	log.Info("first message")
+	log.Get("/compress/text").Info("second message")
+	log.SetDefault(conf)
+	log.Info("third message")
+	log.Get("/compress/text").Info("fourth message")
+	log.CloseLogFiles()
+	require.NoError(t, temp.Close())
+
+	fh, err := os.Open(temp.Name())
+	require.NoError(t, err)
+	defer func() { _ = fh.Close() }()
+	zr, err := gzip.NewReader(fh)
+	require.NoError(t, err)
+	bts, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bts)), "\n")
+	require.Equal(t, 4, len(lines))
+	for i, msg := range []string{"first message", "second message", "third message", "fourth message"} {
+		require.Contains(t, lines[i], msg)
+	}
+}
+
+func TestWithMap(t *testing.T) {
+	lg := log.New(&log.Config{Level: "info", Handler: "memory"})
+	handler := lg.Handler().(*memory.Handler)
+
+	wm := lg.WithMap(map[string]interface{}{"c": 3, "a": 1, "b": "two"})
+	wm.Info("message", "key", "value")
+	wm.WithMap(map[string]interface{}{"d": 4, "a": 0}).Info("merged")
+	wm.Debug("not logged")
+
+	require.Equal(t, 2, len(handler.Entries))
+	require.Equal(t, []string{"a", "b", "c", "key"}, fieldNames(handler.Entries[0].Fields))
+	require.Equal(t, 1, handler.Entries[0].Fields.Get("a"))
+	require.Equal(t, "two", handler.Entries[0].Fields.Get("b"))
+	require.Equal(t, 3, handler.Entries[0].Fields.Get("c"))
+	require.Equal(t, "value", handler.Entries[0].Fields.Get("key"))
+	require.Equal(t, []string{"a", "b", "c", "d"}, fieldNames(handler.Entries[1].Fields))
+	require.Equal(t, 0, handler.Entries[1].Fields.Get("a"))
+
+	// follows level changes of the parent
+	lg.SetDebug()
+	wm.Debug("logged")
+	require.Equal(t, 3, len(handler.Entries))
+	require.Equal(t, "logged", handler.Entries[2].Message)
+	require.NoError(t, wm.Close())
+
+	// maps in log calls
+	lg.Info("message", "key", "value", map[string]interface{}{"y": 2, "x": 1}, io.EOF,
+		"map", map[string]interface{}{"nested": true})
+	e := handler.Entries[3]
+	require.Equal(t, []string{"key", "x", "y", "error", "map"}, fieldNames(e.Fields))
+	require.Equal(t, 1, e.Fields.Get("x"))
+	require.Equal(t, map[string]interface{}{"nested": true}, e.Fields.Get("map"))
+}
+
+func TestHandlerName(t *testing.T) {
+	require.Equal(t, "text", log.New(&log.Config{Handler: "text"}).HandlerName())
+	require.Equal(t, "json-sorted", log.New(&log.Config{Handler: "json-sorted"}).HandlerName())
+	require.Equal(t, "json", log.New(&log.Config{Handler: "fancy"}).HandlerName())
+	require.Equal(t, "json", log.New(&log.Config{}).HandlerName())
+
+	// inherited from the parent config
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "discard",
+		Named: map[string]*log.Config{
+			"/handler/own": {Handler: "memory"},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+	require.Equal(t, "discard", log.Get("/handler/inherited").HandlerName())
+	require.Equal(t, "memory", log.Get("/handler/own").HandlerName())
+	require.Equal(t, "memory", log.Get("/handler/own/sub").HandlerName())
+}
+
+func TestSetHandler(t *testing.T) {
+	lg := log.New(&log.Config{Level: "info", Handler: "memory"})
+	first := lg.Handler().(*memory.Handler)
+
+	const count = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < count; i++ {
+			lg.Info("message", "i", i)
+		}
+	}()
+
+	handlers := []*memory.Handler{first}
+	for i := 0; i < 10; i++ {
+		h := memory.New()
+		lg.SetHandler(h)
+		require.Equal(t, h, lg.Handler())
+		handlers = append(handlers, h)
+	}
+	<-done
+
+	total := 0
+	for _, h := range handlers {
+		total += len(h.Entries)
+	}
+	require.Equal(t, count, total)
+
+	// derived logs follow the parent's handler
+	last := memory.New()
+	lg.WithMap(map[string]interface{}{"k": "v"}).SetHandler(last)
+	lg.Info("last")
+	require.Equal(t, 1, len(last.Entries))
+}
+
+func TestAt(t *testing.T) {
+	backfill := utc.MustParse("2020-05-01T10:20:30.456Z")
+
+	for _, test := range []struct {
+		name    string
+		handler func(w io.Writer) apex.Handler
+		want    string
+	}{
+		{"text", func(w io.Writer) apex.Handler { return text.New(w) }, "2020-05-01T10:20:30.456Z INFO"},
+		{"json", func(w io.Writer) apex.Handler { return ejson.New(w) }, `"timestamp":"2020-05-01T10:20:30.456Z"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			lg := log.New(&log.Config{Level: "info"})
+			buf := &bytes.Buffer{}
+			lg.SetHandler(test.handler(buf))
+
+			lg.At(backfill).Info("historical event", "key", "value")
+			require.Contains(t, buf.String(), test.want)
+			require.Contains(t, buf.String(), "historical event")
+
+			buf.Reset()
+			lg.Info("current event")
+			require.NotContains(t, buf.String(), "2020-05-01")
+
+			// fields and timestamp combine
+			buf.Reset()
+			lg.WithMap(map[string]interface{}{"k": "v"}).At(backfill).WithMap(map[string]interface{}{"x": 1}).Info("combined")
+			require.Contains(t, buf.String(), test.want)
+			require.Contains(t, buf.String(), "k")
+			require.Contains(t, buf.String(), "x")
+		})
+	}
+}
+
+func TestCategory(t *testing.T) {
+	lg := log.New(&log.Config{Level: "info", Handler: "memory"})
+	handler := lg.Handler().(*memory.Handler)
+
+	audit := lg.Category("audit")
+	audit.Info("user login", "user", "joe")
+	audit.Debug("not logged")
+	audit.Warn("permission denied")
+	lg.Info("regular")
+
+	require.Equal(t, 3, len(handler.Entries))
+	require.Equal(t, "audit", handler.Entries[0].Fields.Get("category"))
+	require.Equal(t, "joe", handler.Entries[0].Fields.Get("user"))
+	require.Equal(t, apex.WarnLevel, handler.Entries[1].Level)
+	require.Equal(t, "audit", handler.Entries[1].Fields.Get("category"))
+	require.Nil(t, handler.Entries[2].Fields.Get("category"))
+
+	lg.SetDebug()
+	audit.Debug("logged")
+	require.Equal(t, 4, len(handler.Entries))
+	require.Equal(t, "audit", handler.Entries[3].Fields.Get("category"))
+}
+
+func TestCaller(t *testing.T) {
+	trueVal := true
+	c := &log.Config{Level: "debug", Handler: "memory", Caller: &trueVal}
+	log.SetDefault(c)
+	defer log.SetDefault(log.NewConfig())
+	handler := log.Root().Handler().(*memory.Handler)
+
+	// lineAbove returns the line above the call site
+	lineAbove := func() string {
+		_, _, line, _ := runtime.Caller(1)
+		return fmt.Sprintf("log_test.go:%d", line-1)
+	}
+
+	var want []string
+	lg := log.Get("/caller")
+	lg.Info("direct")
+	want = append(want, lineAbove())
+	log.Info("package-level")
+	want = append(want, lineAbove())
+	lg.Burst("caller", 1, time.Hour).Warn("throttled")
+	want = append(want, lineAbove())
+	_ = log.ErrorE(io.EOF, "package-level helper")
+	want = append(want, lineAbove())
+	lg.WithMap(map[string]interface{}{"k": "v"}).Debug("derived")
+	want = append(want, lineAbove())
+
+	require.Equal(t, len(want), len(handler.Entries))
+	for i, e := range handler.Entries {
+		caller, ok := e.Fields.Get("caller").(log.CallerInfo)
+		require.True(t, ok)
+		require.Equal(t, want[i], caller.String(), e.Message)
+		require.Equal(t, "github.com/eluv-io/log-go_test.TestCaller", caller.Func)
+	}
+
+	// rendered as "file:line" by default, e.g. by the apex json handler
+	buf := &bytes.Buffer{}
+	lg = log.New(&log.Config{Level: "info", Handler: "json", Caller: &trueVal})
+	lg.Handler().(*ljson.Handler).Encoder = json.NewEncoder(buf)
+	lg.Info("json")
+	require.Contains(t, buf.String(), fmt.Sprintf(`"caller":"%s"`, lineAbove()))
+}
+
+func TestCallerMinLevel(t *testing.T) {
+	lg := log.New(&log.Config{Level: "debug", Handler: "memory", CallerMinLevel: "warn"})
+	handler := lg.Handler().(*memory.Handler)
+
+	lg.Debug("debug")
+	lg.Info("info")
+	lg.Warn("warn")
+	lg.Error("error")
+
+	require.Equal(t, 4, len(handler.Entries))
+	for _, e := range handler.Entries {
+		caller := e.Fields.Get("caller")
+		switch e.Message {
+		case "debug", "info":
+			require.Nil(t, caller, e.Message)
+		default:
+			require.Contains(t, fmt.Sprint(caller), "log_test.go:", e.Message)
+		}
+	}
+
+	// an invalid level falls back to Caller
+	trueVal := true
+	lg = log.New(&log.Config{Level: "debug", Handler: "memory", CallerMinLevel: "sometimes", Caller: &trueVal})
+	handler = lg.Handler().(*memory.Handler)
+	lg.Debug("debug")
+	require.NotNil(t, handler.Entries[0].Fields.Get("caller"))
+}
+
+func TestAlways(t *testing.T) {
+	lg := log.New(&log.Config{Level: "error", Handler: "memory"})
+	handler := lg.Handler().(*memory.Handler)
+
+	lg.Info("not logged")
+	lg.Always("info", "audit event", "user", "joe")
+	lg.Always("debug", "debug event")
+	lg.Always("fatal", "fatal event")
+	lg.Always("loud", "unknown level")
+	lg.WithMap(map[string]interface{}{"k": "v"}).Always("warn", "derived event")
+	lg.Info("still not logged")
+
+	require.Equal(t, 5, len(handler.Entries))
+	want := []struct{ level, msg string }{
+		{"info", "audit event"},
+		{"debug", "debug event"},
+		{"error", "fatal event"},
+		{"info", "unknown level"},
+		{"warn", "derived event"},
+	}
+	for i, w := range want {
+		require.Equal(t, w.level, handler.Entries[i].Level.String())
+		require.Equal(t, w.msg, handler.Entries[i].Message)
+	}
+	require.Equal(t, "joe", handler.Entries[0].Fields.Get("user"))
+	require.Equal(t, "v", handler.Entries[4].Fields.Get("k"))
+	require.False(t, lg.IsInfo())
+}
+
+func TestLevelFunc(t *testing.T) {
+	handler := logtest.SetDefaultForTest(t, &log.Config{Level: "info"})
+
+	calls := 0
+	fields := func() []interface{} {
+		calls++
+		return []interface{}{"call", calls}
+	}
+
+	log.TraceFunc("trace", fields)
+	log.DebugFunc("debug", fields)
+	log.Get("/func").DebugFunc("debug", fields)
+	require.Equal(t, 0, calls)
+	require.Equal(t, 0, len(handler.Entries))
+
+	log.InfoFunc("info", fields)
+	log.WarnFunc("warn", fields)
+	log.Get("/func").ErrorFunc("error", fields)
+	log.InfoFunc("no fields", nil)
+	require.Equal(t, 3, calls)
+	require.Equal(t, 4, len(handler.Entries))
+	for i, msg := range []string{"info", "warn", "error"} {
+		require.Equal(t, msg, handler.Entries[i].Message)
+		require.Equal(t, i+1, handler.Entries[i].Fields.Get("call"))
+	}
+}
+
+func TestMeta(t *testing.T) {
+	lg := log.Get("/meta")
+	require.Nil(t, lg.Meta("component"))
+
+	type component struct{ name string }
+	comp := &component{name: "db"}
+	lg.SetMeta("component", comp)
+	require.Same(t, comp, lg.Meta("component"))
+	require.Same(t, comp, log.Get("/meta").Meta("component"))
+	require.Nil(t, log.Get("/meta/sub").Meta("component"))
+
+	// retained on reconfiguration
+	log.SetDefault(&log.Config{Level: "debug", Handler: "discard"})
+	defer log.SetDefault(log.NewConfig())
+	require.Same(t, comp, lg.Meta("component"))
+
+	lg.SetMeta("component", nil)
+	require.Nil(t, lg.Meta("component"))
+}
+
+func TestErrorE(t *testing.T) {
+	logger := log.New(
+		&log.Config{
+			Handler: "memory",
+			Level:   "debug",
+		})
+	handler := logger.Handler().(*memory.Handler)
+
+	err := io.EOF
+	require.Equal(t, err, logger.ErrorE(err, "read failed", "file", "a.txt"))
+	require.Equal(t, err, logger.WarnE(err, "read failed"))
+	require.Equal(t, err, logger.InfoE(err, "read failed"))
+	require.NoError(t, logger.InfoE(nil, "read succeeded"))
+
+	require.Equal(t, 4, len(handler.Entries))
+	for i, level := range []string{"error", "warn", "info"} {
+		require.Equal(t, "read failed", handler.Entries[i].Message)
+		require.Equal(t, level, handler.Entries[i].Level.String())
+		require.Equal(t, err.Error(), handler.Entries[i].Fields.Get("error"))
+	}
+	require.Equal(t, "a.txt", handler.Entries[0].Fields.Get("file"))
+	require.Nil(t, handler.Entries[3].Fields.Get("error"))
+}
+
+func TestWrapError(t *testing.T) {
+	lg, handler := newMemoryLog("info")
+
+	err := errors.E("read", errors.K.IO, io.EOF)
+	ret := lg.WrapError(err, "read failed", "file", "a.txt")
+	require.Same(t, err, ret)
+	require.NoError(t, lg.WrapError(nil, "read failed", "file", "b.txt"))
+
+	require.Equal(t, 1, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "error", e.Level.String())
+	require.Equal(t, "read failed", e.Message)
+	require.Equal(t, "a.txt", e.Fields.Get("file"))
+	require.Same(t, err, e.Fields.Get("error"))
+}
+
+func TestLogLevel(t *testing.T) {
+	logger := log.New(
+		&log.Config{
+			Handler: "memory",
+			Level:   "info",
+		})
+	handler := logger.Handler().(*memory.Handler)
+
+	logger.Log("warn", "retrying", "retries", 3)
+	logger.Log("debug", "not logged")
+	logger.Log("invalid", "logged at info")
+
+	require.Equal(t, 2, len(handler.Entries))
+	require.Equal(t, "retrying", handler.Entries[0].Message)
+	require.Equal(t, "warn", handler.Entries[0].Level.String())
+	require.Equal(t, 3, handler.Entries[0].Fields.Get("retries"))
+	require.Equal(t, "info", handler.Entries[1].Level.String())
+}
+
+func TestBytesFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: "0x68690a"},
+		{format: "hex", want: "0x68690a"},
+		{format: "base64", want: "aGkK"},
+		{format: "string", want: "hi\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			logger := log.New(
+				&log.Config{
+					Handler:     "memory",
+					Level:       "debug",
+					BytesFormat: test.format,
+				})
+			handler := logger.Handler().(*memory.Handler)
+
+			fields := []interface{}{"data", []byte("hi\n"), "raw", json.RawMessage(`{}`)}
+			logger.Info("bytes", fields...)
+
+			require.Equal(t, 1, len(handler.Entries))
+			require.Equal(t, test.want, handler.Entries[0].Fields.Get("data"))
+			require.Equal(t, json.RawMessage(`{}`), handler.Entries[0].Fields.Get("raw"))
+			// the caller's fields are not modified
+			require.Equal(t, []byte("hi\n"), fields[1])
+		})
+	}
+}
+
+func TestEnforceUTF8(t *testing.T) {
+	for _, enforce := range []bool{true, false} {
+		enforce := enforce
+		logger := log.New(
+			&log.Config{
+				Handler:     "memory",
+				Level:       "debug",
+				BytesFormat: "string",
+				EnforceUTF8: &enforce,
+			})
+		handler := logger.Handler().(*memory.Handler)
+
+		logger.Info("bad \xff message", "str", "a\xffb", "bytes", []byte("c\xfe"), "valid", "äöü")
+
+		e := handler.Entries[0]
+		if enforce {
+			require.Equal(t, "bad \uFFFD message", e.Message)
+			require.Equal(t, "a\uFFFDb", e.Fields.Get("str"))
+			require.Equal(t, "c\uFFFD", e.Fields.Get("bytes"))
+		} else {
+			require.Equal(t, "bad \xff message", e.Message)
+			require.Equal(t, "a\xffb", e.Fields.Get("str"))
+			require.Equal(t, "c\xfe", e.Fields.Get("bytes"))
+		}
+		require.Equal(t, "äöü", e.Fields.Get("valid"))
+	}
+}
+
 type Address struct {
 	Name    string
 	Street  string
@@ -178,6 +1162,384 @@ func doTest(t *testing.T, handler *memory.Handler, f func(msg string, fields ...
 	handler.Entries = nil // clear previous entries
 
 }
+func TestJSONNestedValues(t *testing.T) {
+	logger := log.New(
+		&log.Config{
+			Handler: "json",
+			Level:   "debug",
+		})
+	handler := logger.Handler().(*ljson.Handler)
+	buf := &bytes.Buffer{}
+	handler.Encoder = json.NewEncoder(buf)
+
+	logger.Info("message", "address", address, "counts", map[string]int{"a": 1})
+
+	line := struct {
+		Fields struct {
+			Address map[string]interface{} `json:"address"`
+			Counts  map[string]interface{} `json:"counts"`
+		} `json:"fields"`
+	}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "Sesame Street 1", line.Fields.Address["Street"])
+	require.Equal(t, float64(99999), line.Fields.Address["Zip"])
+	require.Equal(t, float64(1), line.Fields.Counts["a"])
+}
+
+func TestStrictFields(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "memory", StrictFields: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+
+	lg.Info("odd", "user", "me", "dangling")
+	require.Equal(t, 2, len(handler.Entries))
+	warning := handler.Entries[0]
+	require.Equal(t, "warn", warning.Level.String())
+	require.Equal(t, "malformed log fields", warning.Message)
+	require.Equal(t, "value without key", warning.Fields.Get("reason"))
+	require.Equal(t, "dangling", warning.Fields.Get("value"))
+	_, file, _, _ := runtime.Caller(0)
+	require.Contains(t, fmt.Sprint(warning.Fields.Get("caller")), filepath.Base(file))
+	// the entry is still logged with the "unknown" fallback
+	require.Equal(t, "odd", handler.Entries[1].Message)
+	require.Equal(t, "dangling", handler.Entries[1].Fields.Get("unknown"))
+
+	handler.Entries = nil
+	lg.Info("non-string key", 42, "value")
+	require.Equal(t, 2, len(handler.Entries))
+	require.Equal(t, "key is not a string", handler.Entries[0].Fields.Get("reason"))
+
+	// well-formed fields
+	handler.Entries = nil
+	lg.Info("ok", "user", "me", io.EOF, apex.Fields{{Name: "a", Value: 1}}, "bytes", []byte("x"))
+	lg.Info("slice", []interface{}{"user", "me"})
+	lg.Info("map", map[string]interface{}{"user": "me"}, "bytes", []byte("x"))
+	require.Equal(t, 3, len(handler.Entries))
+	require.Equal(t, "me", handler.Entries[2].Fields.Get("user"))
+
+	// lenient by default
+	lg = log.New(&log.Config{Level: "info", Handler: "memory"})
+	handler = lg.Handler().(*memory.Handler)
+	lg.Info("odd", "user", "me", "dangling")
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "dangling", handler.Entries[0].Fields.Get("unknown"))
+}
+
+func TestSequence(t *testing.T) {
+	trueVal := true
+	handler := logtest.SetDefaultForTest(t, &log.Config{Level: "info", Sequence: &trueVal})
+	named := log.Get("/seq")
+
+	const goroutines = 10
+	const count = 100
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				if i%2 == 0 {
+					log.Info("message")
+				} else {
+					named.Info("message")
+				}
+				log.Debug("not logged")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, goroutines*count, len(handler.Entries))
+	seen := make(map[uint64]bool)
+	first, last := ^uint64(0), uint64(0)
+	for _, e := range handler.Entries {
+		seq, ok := e.Fields.Get("seq").(uint64)
+		require.True(t, ok)
+		require.False(t, seen[seq])
+		seen[seq] = true
+		if seq < first {
+			first = seq
+		}
+		if seq > last {
+			last = seq
+		}
+	}
+	require.Equal(t, uint64(goroutines*count-1), last-first)
+
+	// numbering continues after a config change
+	handler = logtest.SetDefaultForTest(t, &log.Config{Level: "info", Handler: "memory", Sequence: &trueVal})
+	log.Info("message")
+	named.Info("message")
+	require.Equal(t, last+1, handler.Entries[0].Fields.Get("seq"))
+	require.Equal(t, last+2, handler.Entries[1].Fields.Get("seq"))
+}
+
+func TestExtractErrorKind(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "memory", ExtractErrorKind: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+
+	err := errors.E("read", errors.K.Invalid, "reason", "bad input")
+	lg.Warn("failed", err, "key", "value")
+	lg.Warn("wrapped", "key", "value", fmt.Errorf("wrapped: %w", err))
+	lg.Warn("no kind", io.EOF)
+	lg.Warn("keyed", "key", "value", "error", err)
+	lg.Warn("custom key", "cause", err)
+
+	require.Equal(t, 5, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, []string{"error", "key", "error_kind"}, fieldNames(e.Fields))
+	require.Equal(t, string(errors.K.Invalid), e.Fields.Get("error_kind"))
+	require.Equal(t, err, e.Fields.Get("error"))
+	require.Equal(t, string(errors.K.Invalid), handler.Entries[1].Fields.Get("error_kind"))
+	require.Nil(t, handler.Entries[2].Fields.Get("error_kind"))
+	require.Equal(t, string(errors.K.Invalid), handler.Entries[3].Fields.Get("error_kind"))
+	require.Equal(t, err, handler.Entries[3].Fields.Get("error"))
+	require.Equal(t, string(errors.K.Invalid), handler.Entries[4].Fields.Get("error_kind"))
+
+	// disabled by default
+	lg = log.New(&log.Config{Level: "info", Handler: "memory"})
+	handler = lg.Handler().(*memory.Handler)
+	lg.Warn("failed", err)
+	require.Nil(t, handler.Entries[0].Fields.Get("error_kind"))
+}
+
+func TestOmitEmptyFields(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "memory", OmitEmptyFields: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+
+	var nilPtr *int
+	lg.Info("request", "referrer", "", "count", 0, "ok", false, io.EOF,
+		"request_id", nil, "ptr", nilPtr, "path", "/q")
+
+	require.Equal(t, 1, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, []string{"count", "ok", "error", "path"}, fieldNames(e.Fields))
+	require.Equal(t, 0, e.Fields.Get("count"))
+	require.Equal(t, false, e.Fields.Get("ok"))
+
+	// disabled by default
+	lg = log.New(&log.Config{Level: "info", Handler: "memory"})
+	handler = lg.Handler().(*memory.Handler)
+	lg.Info("request", "referrer", "", "count", 0)
+	require.Equal(t, []string{"referrer", "count"}, fieldNames(handler.Entries[0].Fields))
+}
+
+func TestMaxFields(t *testing.T) {
+	trueVal := true
+	lg := log.New(&log.Config{Level: "info", Handler: "memory", MaxFields: 10, GoRoutineID: &trueVal})
+	handler := lg.Handler().(*memory.Handler)
+
+	var fields []interface{}
+	for i := 0; i < 50; i++ {
+		fields = append(fields, fmt.Sprintf("f%02d", i), i)
+	}
+	lg.Info("many fields", fields...)
+	lg.Info("few fields", fields[:20]...)
+	lg.Info("error", append([]interface{}{io.EOF}, fields[:20]...)...)
+
+	require.Equal(t, 3, len(handler.Entries))
+	names := fieldNames(handler.Entries[0].Fields)
+	require.Equal(t, []string{"gid", "f00", "f01", "f02", "f03", "f04", "f05", "f06", "f07", "f08", "f09", "fields_truncated"}, names)
+	require.Equal(t, 40, handler.Entries[0].Fields.Get("fields_truncated"))
+
+	require.Equal(t, 11, len(handler.Entries[1].Fields))
+	require.Nil(t, handler.Entries[1].Fields.Get("fields_truncated"))
+
+	// errors count as field
+	require.NotNil(t, handler.Entries[2].Fields.Get("error"))
+	require.Equal(t, 1, handler.Entries[2].Fields.Get("fields_truncated"))
+	require.Nil(t, handler.Entries[2].Fields.Get("f09"))
+
+	// apex fields count as the number of fields they hold
+	var af apex.Fields
+	for i := 0; i < 5; i++ {
+		af = append(af, &apex.Field{Name: fmt.Sprintf("a%d", i), Value: i})
+	}
+	handler.Entries = nil
+	lg.Info("apex fields", append([]interface{}{"f", 0, af}, fields[:20]...)...)
+	names = fieldNames(handler.Entries[0].Fields)
+	require.Equal(t, []string{"gid", "f", "a0", "a1", "a2", "a3", "a4", "f00", "f01", "f02", "f03", "fields_truncated"}, names)
+	require.Equal(t, 6, handler.Entries[0].Fields.Get("fields_truncated"))
+
+	handler.Entries = nil
+	lg.Info("apex fields", "f", 0, "g", 1, af, af)
+	require.Equal(t, 5, handler.Entries[0].Fields.Get("fields_truncated"))
+	require.Equal(t, 9, len(handler.Entries[0].Fields)) // gid, f, g, a0-a4, fields_truncated
+}
+
+func TestFieldTypes(t *testing.T) {
+	logger := log.New(
+		&log.Config{
+			Handler:    "json",
+			Level:      "debug",
+			FieldTypes: map[string]string{"gid": "int", "ratio": "float", "id": "string"},
+		})
+	handler := logger.Handler().(*ljson.Handler)
+	buf := &bytes.Buffer{}
+	handler.Encoder = json.NewEncoder(buf)
+
+	logger.Info("message", "gid", 17, "ratio", 1, "id", 42)
+	logger.Info("message", "gid", int64(17), "ratio", float32(1), "id", "42")
+	logger.Info("message", io.EOF, "gid", 17.0, "ratio", uint8(1), "id", int64(42))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, 3, len(lines))
+	fields := func(line string) string {
+		m := map[string]json.RawMessage{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		f := map[string]json.RawMessage{}
+		require.NoError(t, json.Unmarshal(m["fields"], &f))
+		return fmt.Sprintf("gid=%s ratio=%s id=%s", f["gid"], f["ratio"], f["id"])
+	}
+	for _, line := range lines {
+		require.Equal(t, `gid=17 ratio=1 id="42"`, fields(line))
+	}
+
+	mem := log.New(
+		&log.Config{
+			Handler:    "memory",
+			Level:      "debug",
+			FieldTypes: map[string]string{"gid": "int"},
+		})
+	mh := mem.Handler().(*memory.Handler)
+	mem.Info("message", "gid", 17)
+	mem.Info("message", "gid", int32(17))
+	mem.Info("message", "gid", "not a number")
+	require.Equal(t, int64(17), mh.Entries[0].Fields.Get("gid"))
+	require.Equal(t, int64(17), mh.Entries[1].Fields.Get("gid"))
+	require.Equal(t, "not a number", mh.Entries[2].Fields.Get("gid"))
+}
+
+func TestPrefix(t *testing.T) {
+	dir := t.TempDir()
+	consoleFile := filepath.Join(dir, "console.log")
+	jsonFile := filepath.Join(dir, "json.log")
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "console",
+		File:    &log.LumberjackConfig{Filename: consoleFile},
+		Named: map[string]*log.Config{
+			"/prefix":      {Prefix: "[CACHE]"},
+			"/prefix/json": {Handler: "json", File: &log.LumberjackConfig{Filename: jsonFile}},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+
+	log.Info("root message")
+	log.Get("/prefix/sub").Info("console message")
+	log.Get("/prefix/json").Info("json message")
+	log.CloseLogFiles()
+
+	bts, err := os.ReadFile(consoleFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bts), "[CACHE] console message")
+	require.NotContains(t, string(bts), "[CACHE] root message")
+
+	bts, err = os.ReadFile(jsonFile)
+	require.NoError(t, err)
+	line := struct {
+		Message string `json:"message"`
+	}{}
+	require.NoError(t, json.Unmarshal(bts, &line))
+	require.Equal(t, "json message", line.Message)
+}
+
+func TestSharedFile(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "shared.log")
+	m := &metrics{}
+	log.SetMetrics(m)
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "json",
+		File:    &log.LumberjackConfig{Filename: fname},
+		Named: map[string]*log.Config{
+			"/shared/a": {Handler: "text", File: &log.LumberjackConfig{Filename: fname}},
+			"/shared/b": {Handler: "json", File: &log.LumberjackConfig{Filename: fname, MaxSize: 10}},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+	a := log.Get("/shared/a")
+	b := log.Get("/shared/b")
+	log.SetMetrics(nil)
+	// all logs have their own handler, but share a single file
+	require.NotSame(t, a.Handler(), log.Root().Handler())
+	require.NotSame(t, b.Handler(), log.Root().Handler())
+	require.Equal(t, 3, m.instances)
+	require.Equal(t, 1, m.files)
+
+	payload := strings.Repeat("x", 4096)
+	wg := sync.WaitGroup{}
+	for _, lg := range []*log.Log{a, b, log.Root()} {
+		wg.Add(1)
+		go func(lg *log.Log) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				lg.Info("message", "payload", payload)
+			}
+		}(lg)
+	}
+	wg.Wait()
+	log.CloseLogFiles()
+
+	bts, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bts)), "\n")
+	require.Equal(t, 300, len(lines))
+	texts := 0
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "{") {
+			texts++
+			require.Contains(t, line, "message")
+			require.True(t, strings.HasSuffix(line, "payload="+payload), line)
+			continue
+		}
+		entry := struct {
+			Message string                 `json:"message"`
+			Fields  map[string]interface{} `json:"fields"`
+		}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		require.Equal(t, "message", entry.Message)
+		require.Equal(t, payload, entry.Fields["payload"])
+	}
+	require.Equal(t, 100, texts)
+}
+
+func TestSharedCompressedFile(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "shared.log.gz")
+	compress := true
+	lg := log.New(&log.Config{
+		Level:          "info",
+		Handler:        "json",
+		File:           &log.LumberjackConfig{Filename: fname},
+		CompressStream: &compress,
+	})
+	other := log.New(&log.Config{
+		Level:          "info",
+		Handler:        "text",
+		File:           &log.LumberjackConfig{Filename: fname},
+		CompressStream: &compress,
+	})
+	lg.Info("first message")
+	other.Info("second message")
+	lg.Info("third message")
+	require.NoError(t, lg.Close())
+
+	fh, err := os.Open(fname)
+	require.NoError(t, err)
+	defer func() { _ = fh.Close() }()
+	zr, err := gzip.NewReader(fh)
+	require.NoError(t, err)
+	bts, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bts)), "\n")
+	require.Equal(t, 3, len(lines))
+	require.Contains(t, lines[0], "first message")
+	require.Contains(t, lines[1], "second message")
+	require.Contains(t, lines[2], "third message")
+}
+
 func assertEntries(t *testing.T, handler *memory.Handler, msg string, fields []interface{}) {
 	assert.Equal(t, msg, handler.Entries[0].Message)
 	assert.Equal(t, len(fields)/2+1, len(handler.Entries[0].Fields))
@@ -407,6 +1769,45 @@ func TestSetLevel(t *testing.T) {
 	assertLog("/a/b/c/d", "info", "abcd2")
 }
 
+func TestGoRoutineIDKey(t *testing.T) {
+	gid := true
+	c := &log.Config{
+		Level:          "debug",
+		Handler:        "memory",
+		GoRoutineID:    &gid,
+		GoRoutineIDKey: "goroutine_id",
+		Named: map[string]*log.Config{
+			"/gid": {
+				GoRoutineIDKey: "gid",
+			},
+		},
+	}
+	log.SetDefault(c)
+	handler := log.Root().Handler().(*memory.Handler)
+	handler.Entries = nil
+
+	log.Info("message", "user", "me")
+	require.Equal(t, 1, len(handler.Entries))
+	fields := handler.Entries[0].Fields
+	require.Nil(t, fields.Get("gid"))
+	require.NotNil(t, fields.Get("goroutine_id"))
+	// the goroutine id is still the first field after the logger
+	require.Equal(t, []string{"logger", "goroutine_id", "user"}, fieldNames(fields))
+
+	log.Get("/gid").Info("message")
+	require.Equal(t, 2, len(handler.Entries))
+	require.NotNil(t, handler.Entries[1].Fields.Get("gid"))
+	require.Nil(t, handler.Entries[1].Fields.Get("goroutine_id"))
+}
+
+func fieldNames(fields apex.Fields) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
 // TestConcurrent is meant to be run with -race and output no race
 func TestConcurrent(t *testing.T) {
 	dir, err := os.MkdirTemp(os.TempDir(), "TestConcurrent")
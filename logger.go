@@ -1,24 +1,56 @@
 package log
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/modern-go/gls"
-	"gopkg.in/natefinch/lumberjack.v2"
 
 	apex "github.com/eluv-io/apexlog-go"
 	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+var (
+	// pHostname is a pointer to the cached hostname - nil until determined
+	pHostname atomic.Pointer[string]
+	// pGoroutines is a pointer to the latest goroutine count sample - nil until
+	// sampled
+	pGoroutines atomic.Pointer[goroutineSample]
+	// pOnlyGIDs is a pointer to the set of goroutine ids allowed to log below
+	// Error level - nil if not restricted
+	pOnlyGIDs atomic.Pointer[map[int64]struct{}]
+	// sequence is the counter of the sequence numbers added with Sequence
+	sequence atomic.Uint64
+	// callerPkgPrefix is the prefix of the names of the functions in this
+	// package, whose frames are skipped when determining the caller
+	callerPkgPrefix = reflect.TypeOf(logger{}).PkgPath() + "."
 )
 
 // logger is the actual implementation of a Log
 type logger struct {
-	log        apex.Interface     // log is the logger decorated with the logger name field
-	name       string             // name is the logger's name when created through Get()
-	config     *Config            // the current config
-	lumberjack *lumberjack.Logger // io.WriteCloser that writes to the specified filename.
+	log           apex.Interface // log is the logger decorated with the logger name field
+	name          string         // name is the logger's name when created through Get()
+	config        *Config        // the current config
+	file          *sharedFile    // the log file, if any
+	gzip          *gzipWriter    // compresses the output if CompressStream is enabled
+	levelOverride bool           // true if the level was set programmatically rather than from config
+	levelSource   string         // path of the logger whose config or override supplied the level
+	levelExplicit bool           // true if the level was configured explicitly at levelSource
+	always        bool           // true for the loggers of Always, which bypass EnableOnlyGID
+	callerLevel   *apex.Level    // the parsed CallerMinLevel - nil if not set or invalid
 }
 
 func copyApexLogger(log apex.Interface) apex.Interface {
@@ -41,10 +73,16 @@ func copyApexLogger(log apex.Interface) apex.Interface {
 
 func (l *logger) copy(modFns ...func(l *logger)) *logger {
 	ret := &logger{
-		log:        copyApexLogger(l.log),
-		name:       l.name,
-		config:     l.config,
-		lumberjack: l.lumberjack,
+		log:           copyApexLogger(l.log),
+		name:          l.name,
+		config:        l.config,
+		file:          l.file,
+		gzip:          l.gzip,
+		levelOverride: l.levelOverride,
+		levelSource:   l.levelSource,
+		levelExplicit: l.levelExplicit,
+		always:        l.always,
+		callerLevel:   l.callerLevel,
 	}
 	for _, fn := range modFns {
 		fn(ret)
@@ -52,6 +90,25 @@ func (l *logger) copy(modFns ...func(l *logger)) *logger {
 	return ret
 }
 
+// close closes the output owned by this logger, if any.
+func (l *logger) close() error {
+	if l.gzip != nil {
+		return l.gzip.Close()
+	}
+	if l.file != nil {
+		return l.file.Close()
+	}
+	return nil
+}
+
+// sync flushes the output owned by this logger, if any.
+func (l *logger) sync() error {
+	if l.gzip != nil {
+		return l.gzip.Sync()
+	}
+	return nil
+}
+
 func (l *logger) logger() *apex.Logger {
 	switch al := l.log.(type) {
 	case *apex.Logger:
@@ -63,8 +120,30 @@ func (l *logger) logger() *apex.Logger {
 	}
 }
 
+// handler returns the actual handler of this logger, stripped of any internal
+// wrappers.
 func (l *logger) handler() apex.Handler {
-	return l.logger().Handler
+	h := l.logger().Handler
+	for {
+		w, ok := h.(interface{ unwrap() apex.Handler })
+		if !ok {
+			return h
+		}
+		h = w.unwrap()
+	}
+}
+
+// replaceHandler returns the given chain of internal handler wrappers with the
+// actual handler replaced by h.
+func replaceHandler(chain apex.Handler, h apex.Handler) apex.Handler {
+	switch w := chain.(type) {
+	case *notifyHandler:
+		return &notifyHandler{Handler: replaceHandler(w.Handler, h), logger: w.logger}
+	case *recentHandler:
+		return &recentHandler{Handler: replaceHandler(w.Handler, h), buf: w.buf}
+	default:
+		return h
+	}
 }
 
 // IsTrace returns true if the logger logs in Trace level.
@@ -100,32 +179,40 @@ func (l *logger) IsFatal() bool {
 // Trace logs the given message at the Trace level.
 func (l *logger) Trace(msg string, fields ...interface{}) {
 	metrics().Debug(l.name)
-	if l.IsTrace() {
-		l.log.Trace(msg, l.fields(fields)...)
+	if l.IsTrace() && l.allowGID() && allowEntry(apex.TraceLevel) {
+		args, buf := l.fields(apex.TraceLevel, fields)
+		l.log.Trace(l.message(msg), args...)
+		releaseFields(buf)
 	}
 }
 
 // Debug logs the given message at the Debug level.
 func (l *logger) Debug(msg string, fields ...interface{}) {
 	metrics().Debug(l.name)
-	if l.IsDebug() {
-		l.log.Debug(msg, l.fields(fields)...)
+	if l.IsDebug() && l.allowGID() && allowEntry(apex.DebugLevel) {
+		args, buf := l.fields(apex.DebugLevel, fields)
+		l.log.Debug(l.message(msg), args...)
+		releaseFields(buf)
 	}
 }
 
 // Info logs the given message at the Info level.
 func (l *logger) Info(msg string, fields ...interface{}) {
 	metrics().Info(l.name)
-	if l.IsInfo() {
-		l.log.Info(msg, l.fields(fields)...)
+	if l.IsInfo() && l.allowGID() && allowEntry(apex.InfoLevel) {
+		args, buf := l.fields(apex.InfoLevel, fields)
+		l.log.Info(l.message(msg), args...)
+		releaseFields(buf)
 	}
 }
 
 // Warn logs the given message at the Warn level.
 func (l *logger) Warn(msg string, fields ...interface{}) {
 	metrics().Warn(l.name)
-	if l.IsWarn() {
-		l.log.Warn(msg, l.fields(fields)...)
+	if l.IsWarn() && l.allowGID() && allowEntry(apex.WarnLevel) {
+		args, buf := l.fields(apex.WarnLevel, fields)
+		l.log.Warn(l.message(msg), args...)
+		releaseFields(buf)
 	}
 }
 
@@ -133,32 +220,439 @@ func (l *logger) Warn(msg string, fields ...interface{}) {
 func (l *logger) Error(msg string, fields ...interface{}) {
 	metrics().Error(l.name)
 	if l.IsError() {
-		l.log.Error(msg, l.fields(fields)...)
+		fields, ok := limitErrors(msg, fields)
+		if !ok || !allowEntry(apex.ErrorLevel) {
+			return
+		}
+		args, buf := l.fields(apex.ErrorLevel, fields)
+		l.log.Error(l.message(msg), args...)
+		releaseFields(buf)
 	}
 }
 
 // Fatal logs the given message at the Fatal level.
 func (l *logger) Fatal(msg string, fields ...interface{}) {
-	l.log.Fatal(msg, l.fields(fields)...)
+	allowEntry(apex.FatalLevel) // never dropped, but counted
+	args, _ := l.fields(apex.FatalLevel, fields)
+	l.log.Fatal(l.message(msg), args...)
 }
 
-func (l *logger) fields(args []interface{}) []interface{} {
+// fields returns the fields to log for the given fields of a log call. If the
+// returned buffer is not nil, the fields are backed by the pooled buffer, which
+// must be released with releaseFields once the entry has been handled. The
+// given fields may be owned by the caller: the processing steps return a copy
+// instead of modifying them.
+func (l *logger) fields(level apex.Level, args []interface{}) ([]interface{}, *fieldsBuffer) {
+	args = expandMaps(args)
+	if l.config.StrictFields != nil && *l.config.StrictFields {
+		l.checkFields(args)
+	}
+	args = l.errorKind(l.limitFields(l.omitEmpty(l.normalize(args))))
 	addGID := l.config.GoRoutineID != nil && *l.config.GoRoutineID
-	addCaller := l.config.Caller != nil && *l.config.Caller
-	if !addGID && !addCaller {
-		return args
+	addCaller := l.addCaller(level)
+	addHost := l.config.IncludeHostname != nil && *l.config.IncludeHostname
+	addSeq := l.config.Sequence != nil && *l.config.Sequence
+	addVersion := l.config.IncludeVersion != nil && *l.config.IncludeVersion
+	addGoroutines := l.config.IncludeGoroutineCount != nil && *l.config.IncludeGoroutineCount
+	if !addGID && !addCaller && !addHost && !addSeq && !addVersion && !addGoroutines {
+		return l.coerce(args), nil
 	}
 
-	a := make([]interface{}, 0, len(args)+4)
+	buf := fieldsPool.Get().(*fieldsBuffer)
+	a := buf.fields[:0]
 	if addGID {
-		a = append(a, "gid", goID())
+		key := l.config.GoRoutineIDKey
+		if key == "" {
+			key = "gid"
+		}
+		a = append(a, key, goID())
+	}
+	if addHost {
+		a = append(a, "host", hostname())
+	}
+	if addVersion {
+		a = appendVersionInfo(a)
+	}
+	if addGoroutines {
+		a = append(a, "goroutines", goroutineCount())
+	}
+	if addSeq {
+		a = append(a, "seq", sequence.Add(1))
 	}
 	a = append(a, args...)
 	if addCaller {
-		a = append(a, "caller", caller(2))
+		a = append(a, "caller", l.caller())
+	}
+	buf.fields = a
+
+	return l.coerce(a), buf
+}
+
+// addCaller returns true if caller info is added to entries at the given level:
+// if a valid CallerMinLevel is set, for entries at or above that level,
+// otherwise if Caller is enabled.
+func (l *logger) addCaller(level apex.Level) bool {
+	if l.callerLevel != nil {
+		return level >= *l.callerLevel
+	}
+	return l.config.Caller != nil && *l.config.Caller
+}
+
+// fieldsBuffer is a pooled buffer for the fields of a log call.
+type fieldsBuffer struct {
+	fields []interface{}
+}
+
+// maxPooledFields is the capacity above which field buffers are not returned
+// to the pool, in order not to retain the memory of exceptionally large calls.
+const maxPooledFields = 256
+
+var fieldsPool = sync.Pool{
+	New: func() interface{} {
+		return &fieldsBuffer{fields: make([]interface{}, 0, 32)}
+	},
+}
+
+// releaseFields returns the given buffer to the pool after clearing it, so it
+// does not retain any field values. A nil buffer is ignored.
+func releaseFields(buf *fieldsBuffer) {
+	if buf == nil || cap(buf.fields) > maxPooledFields {
+		return
+	}
+	for i := range buf.fields {
+		buf.fields[i] = nil
+	}
+	buf.fields = buf.fields[:0]
+	fieldsPool.Put(buf)
+}
+
+// checkFields logs a warning if the given fields are malformed: a value without
+// key at the end of an odd-length list, which is logged with key "unknown", or
+// a key that is not a string.
+func (l *logger) checkFields(args []interface{}) {
+	if len(args) == 1 {
+		if _, ok := args[0].([]interface{}); ok {
+			// treated as the fields by apex
+			return
+		}
+	}
+	walkFields(args, func(start, end int) bool {
+		reason := ""
+		if end-start == 2 {
+			if _, ok := args[start].(string); !ok {
+				reason = "key is not a string"
+			}
+		} else if !isKeyless(args[start]) {
+			reason = "value without key"
+		}
+		if reason != "" {
+			l.log.Warn("malformed log fields",
+				"reason", reason,
+				"value", args[start],
+				"caller", l.caller())
+		}
+		return true
+	})
+}
+
+// omitEmpty returns the given fields without the key-value pairs whose value
+// is an empty string, nil or a nil pointer if OmitEmptyFields is configured.
+// Zero numbers and false booleans are retained.
+func (l *logger) omitEmpty(args []interface{}) []interface{} {
+	if l.config.OmitEmptyFields == nil || !*l.config.OmitEmptyFields {
+		return args
+	}
+	var ret []interface{}
+	walkFields(args, func(start, end int) bool {
+		if end-start == 2 && isEmptyValue(args[start+1]) {
+			if ret == nil {
+				ret = make([]interface{}, start, len(args))
+				copy(ret, args[:start])
+			}
+		} else if ret != nil {
+			ret = append(ret, args[start:end]...)
+		}
+		return true
+	})
+	if ret == nil {
+		return args
+	}
+	return ret
+}
+
+// limitFields returns the given fields truncated to MaxFields fields with a
+// 'fields_truncated' field holding the number of dropped fields appended, if
+// MaxFields is configured and exceeded. Errors count as one field, apex fields
+// as the number of fields they hold. The base fields of the logger (e.g.
+// 'logger') and the fields added by the logger (e.g. 'gid') are not subject to
+// the limit.
+func (l *logger) limitFields(args []interface{}) []interface{} {
+	limit := l.config.MaxFields
+	if limit <= 0 {
+		return args
+	}
+	count := 0
+	kept, end := 0, -1 // the number of fields and the end index within the limit
+	walkFields(args, func(start, _ int) bool {
+		n := 1
+		if f, ok := args[start].(apex.Fielder); ok {
+			n = len(f.Fields())
+		}
+		if end < 0 && count+n > limit {
+			kept, end = count, start
+		}
+		count += n
+		return true
+	})
+	if end < 0 {
+		return args
+	}
+	ret := make([]interface{}, end, end+2)
+	copy(ret, args[:end])
+	return append(ret, "fields_truncated", count-kept)
+}
+
+// errorKind returns the given fields with the kind of the first error, with or
+// without key, appended as 'error_kind' field if ExtractErrorKind is configured
+// and the error or one of its causes has a kind.
+func (l *logger) errorKind(args []interface{}) []interface{} {
+	if l.config.ExtractErrorKind == nil || !*l.config.ExtractErrorKind {
+		return args
+	}
+	var err error
+	walkFields(args, func(start, end int) bool {
+		if e, ok := args[start].(error); ok {
+			err = e
+		} else if end-start == 2 {
+			err, _ = args[start+1].(error)
+		}
+		return err == nil
+	})
+	var ke interface{ Kind() errors.Kind }
+	if err == nil || !errors.As(err, &ke) {
+		return args
+	}
+	ret := make([]interface{}, len(args), len(args)+2)
+	copy(ret, args)
+	return append(ret, "error_kind", string(ke.Kind()))
+}
+
+// isEmptyValue returns true if the given value is nil, an empty string or a nil
+// pointer.
+func isEmptyValue(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	}
+	v := reflect.ValueOf(val)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// coerce returns the given fields with the values of the keys configured in
+// FieldTypes converted to the configured type.
+func (l *logger) coerce(args []interface{}) []interface{} {
+	types := l.config.FieldTypes
+	if len(types) == 0 {
+		return args
+	}
+	ret := args
+	copied := false
+	walkFields(args, func(start, end int) bool {
+		if end-start < 2 {
+			return true
+		}
+		key, _ := args[start].(string)
+		if typ, ok := types[key]; ok {
+			if val, ok := coerceValue(typ, args[start+1]); ok {
+				if !copied {
+					ret = append([]interface{}(nil), args...)
+					copied = true
+				}
+				ret[start+1] = val
+			}
+		}
+		return true
+	})
+	return ret
+}
+
+// coerceValue converts the given value to the given type: "int" (int64),
+// "float" (float64) or "string". Only numbers are converted to "int" and
+// "float". Returns false if the value is not converted.
+func coerceValue(typ string, val interface{}) (interface{}, bool) {
+	if typ == "string" {
+		if _, ok := val.(string); ok {
+			return nil, false
+		}
+		return fmt.Sprint(val), true
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch typ {
+		case "int":
+			return v.Int(), true
+		case "float":
+			return float64(v.Int()), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch typ {
+		case "int":
+			return int64(v.Uint()), true
+		case "float":
+			return float64(v.Uint()), true
+		}
+	case reflect.Float32, reflect.Float64:
+		switch typ {
+		case "int":
+			return int64(v.Float()), true
+		case "float":
+			return v.Float(), true
+		}
+	}
+	return nil, false
+}
+
+// normalize returns the given fields with all []byte values converted to
+// strings according to the configured BytesFormat, values of types with a
+// registered serializer converted and, if EnforceUTF8 is configured, invalid
+// UTF-8 in strings replaced.
+func (l *logger) normalize(args []interface{}) []interface{} {
+	enforceUTF8 := l.config.EnforceUTF8 != nil && *l.config.EnforceUTF8
+	copied := false
+	set := func(i int, val interface{}) {
+		if !copied {
+			args = append([]interface{}(nil), args...)
+			copied = true
+		}
+		args[i] = val
+	}
+	for i, arg := range args {
+		switch val := arg.(type) {
+		case []byte:
+			var s string
+			switch l.config.BytesFormat {
+			case "base64":
+				s = base64.StdEncoding.EncodeToString(val)
+			case "string":
+				s = string(val)
+				if enforceUTF8 {
+					s = strings.ToValidUTF8(s, string(utf8.RuneError))
+				}
+			default:
+				s = "0x" + hex.EncodeToString(val)
+			}
+			set(i, s)
+		case string:
+			if enforceUTF8 && !utf8.ValidString(val) {
+				set(i, strings.ToValidUTF8(val, string(utf8.RuneError)))
+			}
+		default:
+			if sv, ok := serialize(arg); ok {
+				set(i, sv)
+			}
+		}
+	}
+	return args
+}
+
+// message returns the given message with invalid UTF-8 replaced if
+// EnforceUTF8 is configured and the configured prefix prepended for textual
+// handlers.
+func (l *logger) message(msg string) string {
+	if l.config.EnforceUTF8 != nil && *l.config.EnforceUTF8 && !utf8.ValidString(msg) {
+		msg = strings.ToValidUTF8(msg, string(utf8.RuneError))
+	}
+	if l.config.Prefix != "" {
+		switch l.config.Handler {
+		case "text", "console", "raw":
+			msg = l.config.Prefix + " " + msg
+		}
+	}
+	return msg
+}
+
+// SetHostname overrides the hostname added to entries of loggers configured
+// with IncludeHostname, e.g. for tests. An empty host restores the hostname
+// reported by the OS.
+func SetHostname(host string) {
+	if host == "" {
+		pHostname.Store(nil)
+		return
+	}
+	pHostname.Store(&host)
+}
+
+// hostname returns the hostname, which is determined once and cached.
+func hostname() string {
+	if h := pHostname.Load(); h != nil {
+		return *h
+	}
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		h = "unknown"
+	}
+	pHostname.CompareAndSwap(nil, &h)
+	return *pHostname.Load()
+}
+
+// EnableOnlyGID restricts logging to the goroutines with the given ids, e.g.
+// for debugging the goroutine handling a specific request: entries below Error
+// level are suppressed in all other goroutines. Calling EnableOnlyGID without
+// ids removes the restriction. The goroutine id of an entry is logged as 'gid'
+// field if GoRoutineID is enabled.
+//
+// Entries logged with Always are not suppressed. Since the restriction is
+// applied when logging, IsDebug and the other level checks still return true
+// in goroutines whose entries are suppressed.
+func EnableOnlyGID(ids ...int64) {
+	if len(ids) == 0 {
+		pOnlyGIDs.Store(nil)
+		return
+	}
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	pOnlyGIDs.Store(&set)
+}
+
+// allowGID returns true if the current goroutine is allowed to log entries
+// below Error level through this logger, see EnableOnlyGID.
+func (l *logger) allowGID() bool {
+	if l.always {
+		return true
+	}
+	set := pOnlyGIDs.Load()
+	if set == nil {
+		return true
 	}
+	_, ok := (*set)[goID()]
+	return ok
+}
+
+// goroutineSampleInterval is the interval at which the goroutine count is
+// sampled for IncludeGoroutineCount.
+const goroutineSampleInterval = 100 * time.Millisecond
 
-	return a
+type goroutineSample struct {
+	count int
+	at    utc.UTC
+}
+
+// goroutineCount returns the number of goroutines, sampled at most once per
+// goroutineSampleInterval.
+func goroutineCount() int {
+	now := utc.Now()
+	s := pGoroutines.Load()
+	if s == nil || now.Sub(s.at) >= goroutineSampleInterval || now.Before(s.at) {
+		s = &goroutineSample{count: runtime.NumGoroutine(), at: now}
+		pGoroutines.Store(s)
+	}
+	return s.count
 }
 
 // goID returns the goroutine id of current goroutine
@@ -166,15 +660,55 @@ func goID() int64 {
 	return gls.GoID()
 }
 
-// caller returns the file and line number of the caller, formatted as "file:line".
-func caller(framesToSkip int) string {
-	_, file, line, ok := runtime.Caller(framesToSkip + 2) // +2 to account for call to *logger
+// CallerInfo is the caller of a log call, added as 'caller' field to the
+// entries of loggers configured with Caller. It is rendered as "file:line" by
+// default, both as text and as json, while handlers may render it differently,
+// e.g. the json handler with WithStructuredCaller.
+type CallerInfo struct {
+	File string // the base name of the source file
+	Line int    // the line number
+	Func string // the fully qualified function name
+}
+
+// String returns the caller formatted as "file:line".
+func (c CallerInfo) String() string {
+	return c.File + ":" + strconv.Itoa(c.Line)
+}
+
+// MarshalJSON marshals the caller as json string formatted as "file:line".
+func (c CallerInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// CallerFrame returns the file, line number and function of the caller.
+func (c CallerInfo) CallerFrame() (file string, line int, fn string) {
+	return c.File, c.Line, c.Func
+}
+
+// caller returns the caller info of a log call.
+func (l *logger) caller() CallerInfo {
+	frame, ok := callerFrame()
 	if !ok {
-		return "?"
+		return CallerInfo{File: "?"}
 	}
+	return CallerInfo{File: path.Base(frame.File), Line: frame.Line, Func: frame.Function}
+}
 
-	files := strings.Split(file, "/")
-	file = files[len(files)-1]
-
-	return fmt.Sprintf("%s:%d", file, line)
+// callerFrame returns the frame of the caller. The caller is the first function
+// outside of this package, so the call site is reported correctly regardless of
+// the frames added within this package, e.g. by the package-level functions,
+// helpers or throttled loggers.
+func callerFrame() (runtime.Frame, bool) {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:]) // skip runtime.Callers and callerFrame
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, callerPkgPrefix) {
+			return frame, true
+		}
+		if !more {
+			return frame, false
+		}
+	}
 }
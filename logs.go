@@ -23,6 +23,14 @@ func CloseLogFiles() {
 	getLogRoot().closeLogs()
 }
 
+// ForceReload closes all log files and re-creates all loggers from the current
+// configuration, even if it is unchanged. Unlike SetDefault with the same
+// config, this re-opens log files that were removed or moved, e.g. by external
+// log rotation.
+func ForceReload() {
+	getLogRoot().forceReload()
+}
+
 // Trace logs the given message at the Trace level.
 func Trace(msg string, fields ...interface{}) {
 	def().Trace(msg, fields...)
@@ -53,6 +61,66 @@ func Fatal(msg string, fields ...interface{}) {
 	def().Fatal(msg, fields...)
 }
 
+// TraceFunc logs the given message at the Trace level with the fields
+// returned by fn. fn is only invoked if the Trace level is enabled.
+func TraceFunc(msg string, fn func() []interface{}) {
+	def().TraceFunc(msg, fn)
+}
+
+// DebugFunc logs the given message at the Debug level with the fields
+// returned by fn. fn is only invoked if the Debug level is enabled.
+func DebugFunc(msg string, fn func() []interface{}) {
+	def().DebugFunc(msg, fn)
+}
+
+// InfoFunc logs the given message at the Info level with the fields returned
+// by fn. fn is only invoked if the Info level is enabled.
+func InfoFunc(msg string, fn func() []interface{}) {
+	def().InfoFunc(msg, fn)
+}
+
+// WarnFunc logs the given message at the Warn level with the fields returned
+// by fn. fn is only invoked if the Warn level is enabled.
+func WarnFunc(msg string, fn func() []interface{}) {
+	def().WarnFunc(msg, fn)
+}
+
+// ErrorFunc logs the given message at the Error level with the fields
+// returned by fn. fn is only invoked if the Error level is enabled.
+func ErrorFunc(msg string, fn func() []interface{}) {
+	def().ErrorFunc(msg, fn)
+}
+
+// Panic logs the given message at the Error level and then panics with the
+// message.
+func Panic(msg string, fields ...interface{}) {
+	def().Panic(msg, fields...)
+}
+
+// ErrorE logs the given message and error at the Error level and returns the
+// error. The entry is logged even if the error is nil, see WrapError.
+func ErrorE(err error, msg string, fields ...interface{}) error {
+	return def().ErrorE(err, msg, fields...)
+}
+
+// WrapError is like ErrorE, but logs nothing and returns nil if the error is
+// nil.
+func WrapError(err error, msg string, fields ...interface{}) error {
+	return def().WrapError(err, msg, fields...)
+}
+
+// WarnE logs the given message and error at the Warn level and returns the
+// error.
+func WarnE(err error, msg string, fields ...interface{}) error {
+	return def().WarnE(err, msg, fields...)
+}
+
+// InfoE logs the given message and error at the Info level and returns the
+// error.
+func InfoE(err error, msg string, fields ...interface{}) error {
+	return def().InfoE(err, msg, fields...)
+}
+
 // IsTrace returns true if the logger logs in Trace level.
 func IsTrace() bool {
 	return def().IsTrace()
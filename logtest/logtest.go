@@ -0,0 +1,43 @@
+// Package logtest provides helpers for using logs in tests.
+package logtest
+
+import (
+	"testing"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+
+	"github.com/eluv-io/log-go"
+)
+
+// WithTestName returns a Log that tags all entries of the given log with a
+// 'test' field holding the name of the given test, which disentangles the
+// interleaved output of parallel tests. Textual handlers (text, console, raw)
+// also prefix messages with the test name in brackets, like Config.Prefix. The
+// tag is removed when the test completes: the returned Log then logs like the
+// given log.
+func WithTestName(t *testing.T, l *log.Log) *log.Log {
+	ret, expire := l.WithTag("test", t.Name())
+	t.Cleanup(expire)
+	return ret
+}
+
+// SetDefaultForTest sets the default configuration for the duration of the
+// given test, using a memory handler that records all entries of the default
+// log and its named logs. The previous default configuration is restored when
+// the test completes. Returns the memory handler:
+//
+//	handler := logtest.SetDefaultForTest(t, &log.Config{Level: "debug"})
+//	log.Info("message")
+//	require.Equal(t, "message", handler.Entries[0].Message)
+//
+// The handler and file settings of the given config (including those of named
+// configs) are ignored. A nil config is equivalent to log.NewConfig(). Tests
+// using SetDefaultForTest must not run in parallel, since the default log is
+// global.
+func SetDefaultForTest(t *testing.T, c *log.Config) *memory.Handler {
+	t.Helper()
+
+	handler, restore := log.SetDefaultMemory(c)
+	t.Cleanup(restore)
+	return handler
+}
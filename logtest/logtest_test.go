@@ -0,0 +1,92 @@
+package logtest_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/text"
+	"github.com/eluv-io/log-go/logtest"
+)
+
+func TestSetDefaultForTest(t *testing.T) {
+	prev := log.Root().Handler()
+
+	t.Run("first", func(t *testing.T) {
+		handler := logtest.SetDefaultForTest(t, &log.Config{
+			Level: "debug",
+			Named: map[string]*log.Config{
+				"/test/first": {Level: "info", Handler: "text"},
+			},
+		})
+		log.Debug("first message")
+		log.Get("/test/first").Debug("not logged")
+		log.Get("/test/first").Info("first named message")
+
+		require.Equal(t, 2, len(handler.Entries))
+		require.Equal(t, "first message", handler.Entries[0].Message)
+		require.Equal(t, "first named message", handler.Entries[1].Message)
+	})
+
+	t.Run("second", func(t *testing.T) {
+		handler := logtest.SetDefaultForTest(t, nil)
+		log.Debug("not logged")
+		log.Info("second message")
+		log.Get("/test/first").Info("second named message")
+
+		require.Equal(t, 2, len(handler.Entries))
+		require.Equal(t, "second message", handler.Entries[0].Message)
+		require.Equal(t, "second named message", handler.Entries[1].Message)
+	})
+
+	t.Run("deferred", func(t *testing.T) {
+		handler := logtest.SetDefaultForTest(t, &log.Config{
+			Level:      "info",
+			DeferUntil: "error",
+			Named: map[string]*log.Config{
+				"/test/deferred": {DeferUntil: "warn"},
+			},
+		})
+		log.Info("held back")
+		log.Get("/test/deferred").Info("named held back")
+		require.Equal(t, 0, len(handler.Entries))
+
+		log.Get("/test/deferred").Warn("named trigger")
+		log.Error("trigger")
+		require.Equal(t, 4, len(handler.Entries))
+		require.Equal(t, "named held back", handler.Entries[0].Message)
+		require.Equal(t, "named trigger", handler.Entries[1].Message)
+		require.Equal(t, "held back", handler.Entries[2].Message)
+		require.Equal(t, "trigger", handler.Entries[3].Message)
+	})
+
+	require.Equal(t, prev, log.Root().Handler())
+}
+
+func TestWithTestName(t *testing.T) {
+	lg := log.New(&log.Config{Level: "info", Handler: "text"})
+	buf := &bytes.Buffer{}
+	lg.SetHandler(text.New(buf))
+
+	var stale *log.Log
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			tl := logtest.WithTestName(t, lg)
+			tl.Info("message", "key", "value")
+			stale = tl
+		})
+	}
+	stale.Info("after test")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, 3, len(lines))
+	require.Contains(t, lines[0], "[TestWithTestName/first] message")
+	require.Contains(t, lines[0], "test=TestWithTestName/first")
+	require.Contains(t, lines[1], "[TestWithTestName/second] message")
+	require.Contains(t, lines[1], "test=TestWithTestName/second")
+	require.NotContains(t, lines[2], "TestWithTestName")
+	require.Contains(t, lines[2], "after test")
+}
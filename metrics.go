@@ -1,6 +1,10 @@
 package log
 
-import "sync/atomic"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Metrics is the interface for collecting log metrics (counters for log calls).
 type Metrics interface {
@@ -18,6 +22,31 @@ type Metrics interface {
 	Debug(logger string)
 }
 
+// CacheMetrics is an optional interface of Metrics instances collecting cache
+// statistics reported with Log.Cache.
+type CacheMetrics interface {
+	// Cache increments the hit or miss counter of the given cache
+	Cache(logger string, cache string, hit bool)
+}
+
+// FileMetrics is an optional interface of Metrics instances collecting log
+// file failures.
+type FileMetrics interface {
+	// FileFallback increments the counter for log files that could not be
+	// created, so that the log falls back to stdout
+	FileFallback(filename string)
+}
+
+// WriteMetrics is an optional interface of Metrics instances collecting the
+// latency of writing log entries.
+type WriteMetrics interface {
+	// WriteLatency reports the time spent handing an entry of the given logger
+	// to its handler, including the time waiting for the handler's write lock.
+	// Under bursts, the latency reveals contention on synchronous handlers,
+	// e.g. when writing to log files.
+	WriteLatency(logger string, d time.Duration)
+}
+
 // =============================================================================
 
 var (
@@ -42,6 +71,7 @@ func (n *noopMetrics) Debug(string)     {}
 
 type metricsWrapper struct {
 	metrics Metrics
+	write   WriteMetrics // nil if metrics does not implement WriteMetrics
 }
 
 func metrics() Metrics {
@@ -58,5 +88,50 @@ func SetMetrics(m Metrics) {
 	if m == nil {
 		m = noMetrics
 	}
-	pMetrics.Store(&metricsWrapper{metrics: m})
+	wm, _ := m.(WriteMetrics)
+	pMetrics.Store(&metricsWrapper{metrics: m, write: wm})
+}
+
+// writeMetrics returns the global Metrics instance if it implements
+// WriteMetrics, nil otherwise.
+func writeMetrics() WriteMetrics {
+	if ret := pMetrics.Load(); ret != nil {
+		return ret.write
+	}
+	return nil
+}
+
+// cacheStats holds the *cacheCounters of caches reported with Log.Cache.
+var cacheStats sync.Map
+
+type cacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// countCache increments the hit or miss counter of the given cache and
+// forwards to the global Metrics instance if it implements CacheMetrics.
+func countCache(logger, cache string, hit bool) {
+	c, ok := cacheStats.Load(cache)
+	if !ok {
+		c, _ = cacheStats.LoadOrStore(cache, &cacheCounters{})
+	}
+	if hit {
+		c.(*cacheCounters).hits.Add(1)
+	} else {
+		c.(*cacheCounters).misses.Add(1)
+	}
+	if m, ok := metrics().(CacheMetrics); ok {
+		m.Cache(logger, cache, hit)
+	}
+}
+
+// CacheStats returns the number of hits and misses reported with Log.Cache for
+// the given cache across all loggers.
+func CacheStats(cache string) (hits, misses int64) {
+	c, ok := cacheStats.Load(cache)
+	if !ok {
+		return 0, 0
+	}
+	return c.(*cacheCounters).hits.Load(), c.(*cacheCounters).misses.Load()
 }
@@ -1,11 +1,14 @@
 package log_test
 
 import (
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/text"
 )
 
 func TestMetrics(t *testing.T) {
@@ -47,3 +50,74 @@ func (m *metrics) Error(string)     { m.error++ }
 func (m *metrics) Warn(string)      { m.warn++ }
 func (m *metrics) Info(string)      { m.info++ }
 func (m *metrics) Debug(string)     { m.debug++ }
+
+func TestCacheMetrics(t *testing.T) {
+	m := &cacheMetrics{}
+	log.SetMetrics(m)
+	defer log.SetMetrics(nil)
+
+	lg := log.New(&log.Config{Level: "info", Handler: "discard"})
+	lg.Cache("test-cache-metrics", true)
+	lg.Cache("test-cache-metrics", false)
+	lg.Cache("test-cache-metrics", true)
+	require.Equal(t, 2, m.hits)
+	require.Equal(t, 1, m.misses)
+}
+
+type cacheMetrics struct {
+	metrics
+	hits, misses int
+}
+
+func (m *cacheMetrics) Cache(_ string, _ string, hit bool) {
+	if hit {
+		m.hits++
+	} else {
+		m.misses++
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	m := &writeMetrics{}
+	log.SetMetrics(m)
+	defer log.SetMetrics(nil)
+
+	lg := log.New(&log.Config{Level: "info", Handler: "text"})
+	lg.SetHandler(text.New(&slowWriter{delay: 10 * time.Millisecond}))
+
+	lg.Info("message")
+	lg.Warn("message")
+
+	require.Equal(t, 2, len(m.latencies))
+	for _, d := range m.latencies {
+		require.GreaterOrEqual(t, d, 10*time.Millisecond)
+	}
+	require.Equal(t, []string{"/"}, m.loggers)
+
+	// not reported without WriteMetrics
+	log.SetMetrics(&metrics{})
+	lg.Info("message")
+	require.Equal(t, 2, len(m.latencies))
+}
+
+type writeMetrics struct {
+	metrics
+	latencies []time.Duration
+	loggers   []string
+}
+
+func (m *writeMetrics) WriteLatency(logger string, d time.Duration) {
+	m.latencies = append(m.latencies, d)
+	if len(m.loggers) == 0 || m.loggers[len(m.loggers)-1] != logger {
+		m.loggers = append(m.loggers, logger)
+	}
+}
+
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return io.Discard.Write(p)
+}
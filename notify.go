@@ -0,0 +1,120 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apex "github.com/eluv-io/apexlog-go"
+)
+
+var (
+	// errorCallbacksMutex serializes modifications of errorCallbacks
+	errorCallbacksMutex sync.Mutex
+	// errorCallbacks is a pointer to the registered error callbacks
+	errorCallbacks atomic.Pointer[[]*errorCallback]
+)
+
+type errorCallback struct {
+	fn func(e *apex.Entry)
+}
+
+// OnError registers a callback that is invoked for every entry logged at the
+// Error or Fatal level, e.g. for alerting. Callbacks are invoked synchronously
+// after the entry has been handled and must not retain the entry. Panics in
+// callbacks are recovered.
+//
+// Returns a function that removes the callback again.
+func OnError(fn func(e *apex.Entry)) (remove func()) {
+	cb := &errorCallback{fn: fn}
+
+	errorCallbacksMutex.Lock()
+	defer errorCallbacksMutex.Unlock()
+
+	var cbs []*errorCallback
+	if p := errorCallbacks.Load(); p != nil {
+		cbs = append(cbs, *p...)
+	}
+	cbs = append(cbs, cb)
+	errorCallbacks.Store(&cbs)
+
+	return func() {
+		errorCallbacksMutex.Lock()
+		defer errorCallbacksMutex.Unlock()
+
+		var cbs []*errorCallback
+		if p := errorCallbacks.Load(); p != nil {
+			for _, c := range *p {
+				if c != cb {
+					cbs = append(cbs, c)
+				}
+			}
+		}
+		errorCallbacks.Store(&cbs)
+	}
+}
+
+// resetErrorCallbacks removes all error callbacks.
+func resetErrorCallbacks() {
+	errorCallbacksMutex.Lock()
+	defer errorCallbacksMutex.Unlock()
+
+	errorCallbacks.Store(nil)
+}
+
+func notifyError(e *apex.Entry) {
+	p := errorCallbacks.Load()
+	if p == nil {
+		return
+	}
+	for _, cb := range *p {
+		cb.invoke(e)
+	}
+}
+
+func (c *errorCallback) invoke(e *apex.Entry) {
+	defer func() {
+		_ = recover()
+	}()
+	c.fn(e)
+}
+
+// =============================================================================
+
+// notifyHandler invokes the error callbacks for all entries at the Error or
+// Fatal level after passing them on to the actual handler. If the global
+// Metrics instance implements WriteMetrics, it also reports the time spent in
+// the actual handler, including the time waiting for its write lock. All
+// entries are captured in the crash buffer if enabled.
+type notifyHandler struct {
+	apex.Handler
+	logger string // the name of the logger for metrics
+}
+
+func (h *notifyHandler) HandleLog(e *apex.Entry) error {
+	var err error
+	if wm := writeMetrics(); wm != nil {
+		start := time.Now()
+		err = h.Handler.HandleLog(e)
+		wm.WriteLatency(h.logger, time.Since(start))
+	} else {
+		err = h.Handler.HandleLog(e)
+	}
+	captureCrashLine(e)
+	if e.Level >= apex.ErrorLevel {
+		notifyError(e)
+	}
+	return err
+}
+
+// Asynchronous returns whether the actual handler is asynchronous.
+func (h *notifyHandler) Asynchronous() bool {
+	if a, ok := h.Handler.(apex.Asynchronous); ok {
+		return a.Asynchronous()
+	}
+	return false
+}
+
+func (h *notifyHandler) unwrap() apex.Handler {
+	return h.Handler
+}
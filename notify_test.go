@@ -0,0 +1,37 @@
+package log_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go"
+)
+
+func TestOnError(t *testing.T) {
+	logger := log.New(
+		&log.Config{
+			Handler: "discard",
+			Level:   "debug",
+		})
+
+	var messages []string
+	remove := log.OnError(func(e *apex.Entry) {
+		messages = append(messages, e.Message+":"+e.Fields.Get("error").(string))
+	})
+	removePanic := log.OnError(func(e *apex.Entry) {
+		panic("callback failure")
+	})
+	defer removePanic()
+
+	logger.Info("info", io.EOF)
+	logger.Warn("warn", io.EOF)
+	logger.Error("error", io.EOF)
+	require.Equal(t, []string{"error:EOF"}, messages)
+
+	remove()
+	logger.Error("error", io.EOF)
+	require.Equal(t, 1, len(messages))
+}
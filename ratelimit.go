@@ -0,0 +1,137 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/utc-go"
+)
+
+var (
+	// pRateLimit is a pointer to the global rate limiter - nil if disabled
+	pRateLimit atomic.Pointer[rateLimiter]
+)
+
+// rateLimitReportInterval is the interval at which dropped entries are
+// reported.
+const rateLimitReportInterval = time.Second
+
+// SetGlobalRateLimit enables a hard cap on the total number of entries emitted
+// per second across all loggers. When the cap is approached, the entries with
+// the lowest severity are dropped first: Trace entries are dropped once a
+// quarter of the cap is reached within the current second, Debug entries at
+// half, Info entries at three quarters and Warn entries at the full cap. Error
+// and Fatal entries are never dropped, but count towards the cap.
+//
+// The number of dropped entries is reported as 'dropped' field in a summary
+// entry at Warn level, which is emitted one second after the first unreported
+// drop. The summary is written with the handler of the default log regardless
+// of its level and is not subject to the limit. Pending drops are reported
+// immediately when the limit is changed.
+//
+// A perSecond value <= 0 disables the limit.
+func SetGlobalRateLimit(perSecond int) {
+	var rl *rateLimiter
+	if perSecond > 0 {
+		rl = &rateLimiter{
+			limit: perSecond,
+			start: utc.Now(),
+		}
+	}
+	if prev := pRateLimit.Swap(rl); prev != nil {
+		prev.stop()
+	}
+}
+
+// allowEntry applies the global rate limit to an entry at the given level.
+// Returns false if the entry has to be dropped, which is never the case for
+// Error and Fatal entries.
+func allowEntry(level apex.Level) bool {
+	rl := pRateLimit.Load()
+	if rl == nil {
+		return true
+	}
+	return rl.allow(level)
+}
+
+// =============================================================================
+
+type rateLimiter struct {
+	limit int
+
+	mutex      sync.Mutex
+	start      utc.UTC     // start of the current second
+	count      int         // number of entries emitted in the current second
+	unreported int         // number of dropped entries not yet reported
+	timer      *time.Timer // the timer reporting the dropped entries - nil if none pending
+}
+
+// allow returns true if an entry at the given level may be emitted.
+func (r *rateLimiter) allow(level apex.Level) bool {
+	now := utc.Now()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if now.Sub(r.start) >= time.Second {
+		r.start = now
+		r.count = 0
+	}
+
+	var threshold int
+	switch level {
+	case apex.TraceLevel:
+		threshold = r.limit / 4
+	case apex.DebugLevel:
+		threshold = r.limit / 2
+	case apex.InfoLevel:
+		threshold = r.limit * 3 / 4
+	case apex.WarnLevel:
+		threshold = r.limit
+	default:
+		r.count++
+		return true
+	}
+	if r.count >= threshold {
+		r.unreported++
+		if r.timer == nil {
+			r.timer = time.AfterFunc(rateLimitReportInterval, r.report)
+		}
+		return false
+	}
+	r.count++
+	return true
+}
+
+// report emits the summary of the entries dropped since the last report.
+func (r *rateLimiter) report() {
+	r.mutex.Lock()
+	dropped := r.unreported
+	r.unreported = 0
+	r.timer = nil
+	r.mutex.Unlock()
+
+	if dropped == 0 {
+		return
+	}
+	// bypass the limiter and the level of the default log
+	lg := &apex.Logger{
+		Handler: def().get().logger().Handler,
+		Level:   apex.WarnLevel,
+	}
+	lg.Warn("global log rate limit exceeded",
+		"dropped", dropped,
+		"limit", r.limit)
+}
+
+// stop stops the report timer and reports pending drops immediately.
+func (r *rateLimiter) stop() {
+	r.mutex.Lock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.mutex.Unlock()
+	r.report()
+}
@@ -0,0 +1,97 @@
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/logtest"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestGlobalRateLimit(t *testing.T) {
+	now := utc.UnixMilli(1_000_000)
+	defer utc.MockNow(now)()
+
+	handler := logtest.SetDefaultForTest(t, &log.Config{Level: "trace"})
+	other := log.Get("/ratelimit/other")
+
+	// thresholds with a cap of 8: trace 2, debug 4, info 6, warn 8
+	log.SetGlobalRateLimit(8)
+	defer log.SetGlobalRateLimit(0)
+
+	for i := 0; i < 10; i++ {
+		log.Info("info")
+	}
+	for i := 0; i < 5; i++ {
+		other.Debug("debug")
+		other.Error("error")
+	}
+	log.Warn("warn")
+
+	count := func(msg string) int {
+		n := 0
+		for _, e := range handler.Entries {
+			if e.Message == msg {
+				n++
+			}
+		}
+		return n
+	}
+	require.Equal(t, 6, count("info"))
+	require.Equal(t, 0, count("debug"))
+	require.Equal(t, 5, count("error"))
+	require.Equal(t, 0, count("warn"))
+	require.Equal(t, 11, len(handler.Entries))
+
+	// summary of the pending drops when the limit is changed
+	log.SetGlobalRateLimit(0)
+	require.Equal(t, 12, len(handler.Entries))
+	summary := handler.Entries[11]
+	require.Equal(t, "global log rate limit exceeded", summary.Message)
+	require.Equal(t, "warn", summary.Level.String())
+	require.Equal(t, 10, summary.Fields.Get("dropped"))
+	require.Equal(t, 8, summary.Fields.Get("limit"))
+
+	// no summary if nothing was dropped
+	log.SetGlobalRateLimit(8)
+	log.Info("info")
+	log.SetGlobalRateLimit(0)
+	require.Equal(t, 13, len(handler.Entries))
+	require.Equal(t, "info", handler.Entries[12].Message)
+}
+
+func TestGlobalRateLimitSummary(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "ratelimit.log")
+	log.SetDefault(&log.Config{
+		Level:   "error",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: fname},
+		Named: map[string]*log.Config{
+			"/ratelimit/summary": {Level: "info"},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+	log.SetGlobalRateLimit(1)
+	defer log.SetGlobalRateLimit(0)
+
+	// the summary is emitted after a second, regardless of the default level
+	named := log.Get("/ratelimit/summary")
+	named.Info("dropped")
+	log.Error("error")
+	named.Warn("dropped")
+	time.Sleep(1500 * time.Millisecond)
+
+	bts, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bts)), "\n")
+	require.Equal(t, 2, len(lines))
+	require.Contains(t, lines[0], "error")
+	require.Contains(t, lines[1], "global log rate limit exceeded")
+	require.Contains(t, lines[1], "dropped=2")
+}
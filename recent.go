@@ -0,0 +1,153 @@
+package log
+
+import (
+	"strings"
+	"sync"
+
+	apex "github.com/eluv-io/apexlog-go"
+)
+
+var (
+	// recentMutex protects recentBuffers
+	recentMutex sync.Mutex
+	// recentBuffers are the buffers of recent entries per logger path
+	recentBuffers = map[string]*recentBuffer{}
+)
+
+// RecentEntriesFor returns up to n of the most recent entries logged with the
+// logger of the given path, oldest first. Entries are only kept for loggers
+// configured with RecentEntries > 0 and are not shared with their parent or
+// child loggers. Returns nil if no entries are available.
+func RecentEntriesFor(path string, n int) []*apex.Entry {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	recentMutex.Lock()
+	buf, found := recentBuffers[path]
+	recentMutex.Unlock()
+
+	if !found {
+		return nil
+	}
+	return buf.last(n)
+}
+
+// resetRecentBuffers removes the buffers of all loggers.
+func resetRecentBuffers() {
+	recentMutex.Lock()
+	defer recentMutex.Unlock()
+
+	recentBuffers = map[string]*recentBuffer{}
+}
+
+// getRecentBuffer returns the buffer for the given path, creating it if it
+// doesn't exist yet and resizing it if its size differs.
+func getRecentBuffer(path string, size int) *recentBuffer {
+	recentMutex.Lock()
+	defer recentMutex.Unlock()
+
+	buf, found := recentBuffers[path]
+	if !found {
+		buf = &recentBuffer{}
+		recentBuffers[path] = buf
+	}
+	buf.resize(size)
+	return buf
+}
+
+// =============================================================================
+
+// recentHandler records all entries in a buffer before passing them on to the
+// actual handler.
+type recentHandler struct {
+	apex.Handler
+	buf *recentBuffer
+}
+
+func newRecentHandler(handler apex.Handler, path string, size int) *recentHandler {
+	return &recentHandler{
+		Handler: handler,
+		buf:     getRecentBuffer(path, size),
+	}
+}
+
+func (h *recentHandler) HandleLog(e *apex.Entry) error {
+	h.buf.add(e)
+	return h.Handler.HandleLog(e)
+}
+
+// Asynchronous returns true since the recorded entries are retained and may
+// therefore not be pooled.
+func (h *recentHandler) Asynchronous() bool {
+	return true
+}
+
+func (h *recentHandler) unwrap() apex.Handler {
+	return h.Handler
+}
+
+// =============================================================================
+
+// recentBuffer is a circular buffer of log entries.
+type recentBuffer struct {
+	mutex   sync.Mutex
+	entries []*apex.Entry
+	next    int  // index of the next entry to write
+	full    bool // true if the buffer has wrapped around
+}
+
+func (b *recentBuffer) add(e *apex.Entry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.entries[b.next] = e
+	b.next++
+	if b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// last returns up to n of the most recent entries, oldest first.
+func (b *recentBuffer) last(n int) []*apex.Entry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.entries)
+	}
+	if n > count {
+		n = count
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	ret := make([]*apex.Entry, n)
+	start := b.next - n
+	if start < 0 {
+		start += len(b.entries)
+	}
+	for i := range ret {
+		ret[i] = b.entries[(start+i)%len(b.entries)]
+	}
+	return ret
+}
+
+// resize changes the size of the buffer, retaining the most recent entries.
+func (b *recentBuffer) resize(size int) {
+	if len(b.entries) == size {
+		return
+	}
+	entries := b.last(size)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.entries = make([]*apex.Entry, size)
+	copy(b.entries, entries)
+	b.next = len(entries) % size
+	b.full = len(entries) == size
+}
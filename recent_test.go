@@ -0,0 +1,59 @@
+package log_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/log-go"
+)
+
+func TestRecentEntriesFor(t *testing.T) {
+	log.SetDefault(&log.Config{
+		Level:         "info",
+		Handler:       "discard",
+		RecentEntries: 3,
+		Named: map[string]*log.Config{
+			"/recent/api": {
+				Handler: "text",
+				File:    &log.LumberjackConfig{Filename: t.TempDir() + "/api.log"},
+			},
+		},
+	})
+	defer log.SetDefault(log.NewConfig())
+
+	db := log.Get("/recent/db")
+	api := log.Get("/recent/api")
+
+	for i := 0; i < 5; i++ {
+		db.Info("query", "count", i)
+	}
+	db.Debug("not logged")
+	api.Warn("request failed")
+
+	messages := func(entries []*apex.Entry) []string {
+		var ret []string
+		for _, e := range entries {
+			ret = append(ret, fmt.Sprint(e.Message, e.Fields.Get("count")))
+		}
+		return ret
+	}
+
+	require.Equal(t, []string{"query2", "query3", "query4"}, messages(log.RecentEntriesFor("/recent/db", 10)))
+	require.Equal(t, []string{"query3", "query4"}, messages(log.RecentEntriesFor("recent/db", 2)))
+	require.Equal(t, []string{"request failed<nil>"}, messages(log.RecentEntriesFor("/recent/api", 10)))
+	require.Empty(t, log.RecentEntriesFor("/recent", 10))
+	require.Empty(t, log.RecentEntriesFor("/recent/unknown", 10))
+
+	// entries are retained when the buffer is resized on reconfiguration
+	log.SetDefault(&log.Config{
+		Level:         "info",
+		Handler:       "discard",
+		RecentEntries: 2,
+	})
+	require.Equal(t, []string{"query3", "query4"}, messages(log.RecentEntriesFor("/recent/db", 10)))
+	db.Info("query", "count", 5)
+	require.Equal(t, []string{"query4", "query5"}, messages(log.RecentEntriesFor("/recent/db", 10)))
+}
@@ -0,0 +1,35 @@
+package log
+
+import (
+	"runtime/debug"
+)
+
+// Recover recovers from a panic, logs it at Error level and re-panics. It is
+// meant to be deferred:
+//
+//	defer lg.Recover("handler panicked", "path", path)
+//
+// The entry contains the recovered value as 'panic' field and the stack trace
+// of the panicking goroutine as 'stacktrace' field.
+func (l *Log) Recover(msg string, fields ...interface{}) {
+	if r := recover(); r != nil {
+		l.logPanic(r, msg, fields)
+		panic(r)
+	}
+}
+
+// RecoverAndContinue is like Recover, but swallows the panic instead of
+// re-panicking, so that the deferring function returns normally.
+func (l *Log) RecoverAndContinue(msg string, fields ...interface{}) {
+	if r := recover(); r != nil {
+		l.logPanic(r, msg, fields)
+	}
+}
+
+func (l *Log) logPanic(r interface{}, msg string, fields []interface{}) {
+	f := make([]interface{}, 0, len(fields)+4)
+	f = append(f, "panic", r)
+	f = append(f, fields...)
+	f = append(f, "stacktrace", string(debug.Stack()))
+	l.get().Error(msg, f...)
+}
@@ -0,0 +1,50 @@
+package log_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+)
+
+func TestRecover(t *testing.T) {
+	lg := log.New(&log.Config{
+		Level:   "info",
+		Handler: "memory",
+	})
+	handler := lg.Handler().(*memory.Handler)
+
+	func() {
+		defer lg.RecoverAndContinue("handler panicked", "path", "/a")
+		panic("boom")
+	}()
+
+	require.Equal(t, 1, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "handler panicked", e.Message)
+	require.Equal(t, "error", e.Level.String())
+	require.Equal(t, "boom", e.Fields.Get("panic"))
+	require.Equal(t, "/a", e.Fields.Get("path"))
+	require.Contains(t, e.Fields.Get("stacktrace"), "TestRecover")
+
+	var repanicked interface{}
+	func() {
+		defer func() {
+			repanicked = recover()
+		}()
+		defer lg.Recover("handler panicked")
+		panic("boom again")
+	}()
+
+	require.Equal(t, "boom again", repanicked)
+	require.Equal(t, 2, len(handler.Entries))
+	require.Equal(t, "boom again", handler.Entries[1].Fields.Get("panic"))
+
+	// no panic: nothing logged
+	func() {
+		defer lg.Recover("handler panicked")
+	}()
+	require.Equal(t, 2, len(handler.Entries))
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/errors-go"
+)
+
+// registeredHandlers holds the handler factories registered with
+// RegisterHandler by name.
+var registeredHandlers sync.Map
+
+// RegisterHandler registers a factory for custom handlers, which may then be
+// selected by name with Config.Handler like the built-in handlers. The factory
+// is called with the configured output (log file or stdout/stderr) whenever a
+// logger with the given handler is created. Registering a name again replaces
+// the previous factory, a nil factory removes the registration.
+//
+// Returns an error if the name is empty or the name of a built-in handler,
+// since these are reserved.
+func RegisterHandler(name string, factory func(w io.Writer) apex.Handler) error {
+	if name == "" || isBuiltinHandler(name) {
+		return errors.E("RegisterHandler", errors.K.Invalid,
+			"reason", "reserved handler name",
+			"handler", name)
+	}
+	if factory == nil {
+		registeredHandlers.Delete(name)
+		return nil
+	}
+	registeredHandlers.Store(name, factory)
+	return nil
+}
+
+// HandlerNames returns the names of all handlers that may be used with
+// Config.Handler: the built-in handlers followed by the registered handlers in
+// alphabetical order.
+func HandlerNames() []string {
+	var registered []string
+	registeredHandlers.Range(func(key, _ interface{}) bool {
+		registered = append(registered, key.(string))
+		return true
+	})
+	sort.Strings(registered)
+	ret := make([]string, 0, len(handlerNames)+len(registered))
+	ret = append(ret, handlerNames...)
+	return append(ret, registered...)
+}
+
+// registeredHandler creates a handler with the factory registered for the given
+// name. Returns false if no factory is registered.
+func registeredHandler(name string, w io.Writer) (apex.Handler, bool) {
+	factory, ok := registeredHandlers.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return factory.(func(w io.Writer) apex.Handler)(w), true
+}
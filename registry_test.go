@@ -0,0 +1,83 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/audit"
+	"github.com/eluv-io/log-go/handlers/cloudevents"
+	"github.com/eluv-io/log-go/handlers/cloudwatch"
+	"github.com/eluv-io/log-go/handlers/console"
+	"github.com/eluv-io/log-go/handlers/dedup"
+	"github.com/eluv-io/log-go/handlers/deferred"
+	"github.com/eluv-io/log-go/handlers/gcp"
+	ejson "github.com/eluv-io/log-go/handlers/json"
+	"github.com/eluv-io/log-go/handlers/raw"
+	"github.com/eluv-io/log-go/handlers/text"
+)
+
+// all handlers of this module implement apex.Handler
+var (
+	_ apex.Handler = (*audit.Handler)(nil)
+	_ apex.Handler = (*cloudevents.Handler)(nil)
+	_ apex.Handler = (*cloudwatch.Handler)(nil)
+	_ apex.Handler = (*console.Handler)(nil)
+	_ apex.Handler = (*dedup.Handler)(nil)
+	_ apex.Handler = (*deferred.Handler)(nil)
+	_ apex.Handler = (*gcp.Handler)(nil)
+	_ apex.Handler = (*ejson.Handler)(nil)
+	_ apex.Handler = (*raw.Handler)(nil)
+	_ apex.Handler = (*text.Handler)(nil)
+)
+
+type prefixHandler struct {
+	w io.Writer
+}
+
+func (h *prefixHandler) HandleLog(e *apex.Entry) error {
+	_, err := io.WriteString(h.w, "custom: "+e.Message+"\n")
+	return err
+}
+
+func TestRegisterHandler(t *testing.T) {
+	err := log.RegisterHandler("custom", func(w io.Writer) apex.Handler {
+		return &prefixHandler{w: w}
+	})
+	require.NoError(t, err)
+	defer func() { _ = log.RegisterHandler("custom", nil) }()
+
+	c := &log.Config{Level: "info", Handler: "custom"}
+	require.NoError(t, c.Validate())
+	require.Contains(t, log.HandlerNames(), "custom")
+
+	buf := &bytes.Buffer{}
+	lg := log.NewLevelWriters(c, map[string]io.Writer{"info+": buf})
+	lg.Info("message")
+	require.Equal(t, "custom: message\n", buf.String())
+
+	lg = log.New(c)
+	_, ok := lg.Handler().(*prefixHandler)
+	require.True(t, ok)
+
+	// kept by ResetForTest
+	log.ResetForTest()
+	require.Contains(t, log.HandlerNames(), "custom")
+
+	// built-in names are reserved
+	for _, name := range []string{"text", ""} {
+		err = log.RegisterHandler(name, func(w io.Writer) apex.Handler { return &prefixHandler{w: w} })
+		require.Error(t, err)
+		require.True(t, errors.IsKind(errors.K.Invalid, err))
+	}
+
+	// unregistered
+	require.NoError(t, log.RegisterHandler("custom", nil))
+	require.Error(t, c.Validate())
+	require.NotContains(t, log.HandlerNames(), "custom")
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+)
+
+// ReplayMemory re-emits the entries captured by the given memory handler
+// through the handler of the given Log, preserving their level, message,
+// fields and timestamp. Entries below the level of the Log are skipped. This
+// supports buffering the logs of early startup in a memory handler and
+// flushing them once the actual configuration is loaded:
+//
+//	early := log.New(&log.Config{Level: "debug", Handler: "memory"})
+//	buffer := early.Handler().(*memory.Handler)
+//	...
+//	log.SetDefault(config)
+//	log.ReplayMemory(buffer, log.Root())
+//
+// The entries are not removed from the memory handler. It must not receive
+// entries concurrently with the replay.
+func ReplayMemory(h *memory.Handler, into *Log) {
+	if h == nil || into == nil {
+		return
+	}
+	al := into.get().logger()
+	for _, e := range h.Entries {
+		if e.Level < al.Level {
+			continue
+		}
+		_ = al.Handler.HandleLog(&apex.Entry{
+			Logger:    al,
+			Fields:    e.Fields,
+			Level:     e.Level,
+			Message:   e.Message,
+			Timestamp: e.Timestamp,
+		})
+	}
+}
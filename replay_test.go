@@ -0,0 +1,40 @@
+package log_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestReplayMemory(t *testing.T) {
+	early, buffer := newMemoryLog("debug")
+	backfill := utc.MustParse("2020-05-01T10:20:30.456Z")
+	early.At(backfill).Debug("loading config", "file", "config.toml")
+	early.Warn("using defaults")
+
+	target, handler := newMemoryLog("info")
+	log.ReplayMemory(buffer, target)
+
+	// the debug entry is below the target's level
+	require.Equal(t, 1, len(handler.Entries))
+	require.Equal(t, "using defaults", handler.Entries[0].Message)
+	require.Equal(t, buffer.Entries[1].Timestamp, handler.Entries[0].Timestamp)
+
+	target.SetDebug()
+	handler.Entries = nil
+	log.ReplayMemory(buffer, target)
+	require.Equal(t, 2, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "debug", e.Level.String())
+	require.Equal(t, "loading config", e.Message)
+	require.Equal(t, "config.toml", e.Fields.Get("file"))
+	require.Equal(t, backfill.Time, e.Timestamp)
+	require.Equal(t, "warn", handler.Entries[1].Level.String())
+
+	log.ReplayMemory(memory.New(), target)
+	require.Equal(t, 2, len(handler.Entries))
+}
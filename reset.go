@@ -0,0 +1,54 @@
+package log
+
+import (
+	"sync"
+)
+
+// ResetForTest restores the package to its initial state, e.g. for isolating
+// tests that call SetDefault, SetMetrics or other global setters:
+//   - closes all log files, forgets the shared file and compressed stream
+//     writers and reinstalls the default configuration
+//   - removes all named logs: Log instances retrieved with Get before the reset
+//     keep their previous configuration and are no longer updated
+//   - resets the metrics to no-op metrics and clears the cache statistics
+//   - restarts the sequence numbers
+//   - removes all running timers, error callbacks registered with OnError, the
+//     recent entries of all loggers and the lines of the crash buffer
+//   - forgets the call sites for which a deprecation was logged
+//   - restores the default serializers, removing those registered with
+//     RegisterSerializer
+//   - removes the hostname override, version info, request ID generator, trace
+//     ID extractor, global rate limit, error fingerprint window and goroutine
+//     restriction
+//
+// Handlers registered with RegisterHandler are kept.
+//
+// ResetForTest is intended for tests and is not safe to call concurrently with
+// logging.
+func ResetForTest() {
+	getLogRoot().doLocked(func(r *logRoot) {
+		resetCrashBuffer()
+		r.closeLogs()
+		r.named = make(map[string]*Log)
+		r.defConfig = nil
+		r.applyConfigNoLock(defaultConfig())
+	})
+	SetMetrics(nil)
+	SetHostname("")
+	SetVersionInfo("", "")
+	SetRequestIDGenerator(nil)
+	SetTraceIDExtractor(nil)
+	SetGlobalRateLimit(0)
+	SetErrorFingerprintWindow(0)
+	EnableOnlyGID()
+	sequence.Store(0)
+	resetErrorCallbacks()
+	resetSerializers()
+	resetRecentBuffers()
+	for _, m := range []*sync.Map{&fileFallbacks, &unknownHandlers, &cacheStats, &timers, &sharedFiles, &streamGzips, &deprecations} {
+		m.Range(func(key, _ interface{}) bool {
+			m.Delete(key)
+			return true
+		})
+	}
+}
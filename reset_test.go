@@ -0,0 +1,71 @@
+package log_test
+
+import (
+	"net"
+	"testing"
+
+	apex "github.com/eluv-io/apexlog-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/log-go/handlers/text"
+)
+
+type resetSample struct{}
+
+func TestResetForTest(t *testing.T) {
+	c := log.NewConfig()
+	c.Level = "debug"
+	c.Handler = "json"
+	c.Named = map[string]*log.Config{
+		"/reset": {Level: "trace", RecentEntries: 5},
+	}
+	log.SetDefault(c)
+	m := &metrics{}
+	log.SetMetrics(m)
+	log.SetRequestIDGenerator(func() string { return "fixed" })
+	errors := 0
+	log.OnError(func(*apex.Entry) { errors++ })
+	log.RegisterSerializer(resetSample{}, func(interface{}) interface{} { return "custom" })
+	named := log.Get("/reset")
+	require.Equal(t, "trace", named.Level())
+	named.Info("recent")
+	require.Len(t, log.RecentEntriesFor("/reset", 5), 1)
+	deprecated := func(lg *log.Log) {
+		lg.Deprecated("old api")
+	}
+	deprecated(log.New(&log.Config{Level: "info", Handler: "discard"}))
+
+	log.ResetForTest()
+
+	require.Equal(t, "info", log.Root().Level())
+	_, ok := log.Root().Handler().(*text.Handler)
+	require.True(t, ok)
+
+	// named logs are re-created from the default config
+	lg := log.Get("/reset")
+	require.NotSame(t, named, lg)
+	require.Equal(t, "info", lg.Level())
+	require.Empty(t, log.RecentEntriesFor("/reset", 5))
+
+	// metrics and error callbacks are no longer invoked
+	log.Error("message")
+	require.Equal(t, 0, m.error)
+	require.Equal(t, 0, errors)
+
+	_, id := lg.WithNewRequestID()
+	require.NotEqual(t, "fixed", id)
+
+	// custom serializers are removed, default serializers are kept
+	lg = log.New(&log.Config{Level: "info", Handler: "memory"})
+	mem := lg.Handler().(*memory.Handler)
+	lg.Info("serialized", "sample", resetSample{}, "ip", net.IPv4(127, 0, 0, 1))
+	require.Equal(t, resetSample{}, mem.Entries[0].Fields.Get("sample"))
+	require.Equal(t, "127.0.0.1", mem.Entries[0].Fields.Get("ip"))
+
+	// deprecations are logged again
+	deprecated(lg)
+	require.Len(t, mem.Entries, 2)
+	require.Equal(t, "old api", mem.Entries[1].Fields.Get("deprecated"))
+}
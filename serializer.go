@@ -0,0 +1,92 @@
+package log
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// serializersMutex serializes modifications of serializers
+	serializersMutex sync.Mutex
+	// serializers is a pointer to the registered value serializers by type
+	serializers atomic.Pointer[map[reflect.Type]func(interface{}) interface{}]
+)
+
+func init() {
+	registerDefaultSerializers()
+}
+
+// registerDefaultSerializers registers the serializers of the types that are
+// serialized by default.
+func registerDefaultSerializers() {
+	stringer := func(v interface{}) interface{} {
+		return v.(interface{ String() string }).String()
+	}
+	RegisterSerializer(net.IP{}, stringer)
+	RegisterSerializer(net.HardwareAddr{}, stringer)
+	RegisterSerializer(&net.IPNet{}, stringer)
+	RegisterSerializer(&url.URL{}, stringer)
+}
+
+// RegisterSerializer registers a serializer for field values of the concrete
+// type of the given sample value. The serializer converts the value before it
+// is passed to the handler and therefore applies to all handlers:
+//
+//	log.RegisterSerializer(uuid.UUID{}, func(v interface{}) interface{} {
+//		return v.(uuid.UUID).String()
+//	})
+//
+// Registering a serializer for a type replaces the previous one, a nil
+// serializer removes it. Serializers are looked up by exact type, i.e. a
+// serializer registered for a struct is not applied to pointers to that
+// struct. Serializers for the types net.IP, net.HardwareAddr, *net.IPNet and
+// *url.URL are registered by default and convert values to their string
+// representation.
+//
+// Serializers for string types or error types should not be registered, since
+// they would also apply to field keys and keyless errors, respectively.
+func RegisterSerializer(sample interface{}, fn func(v interface{}) interface{}) {
+	typ := reflect.TypeOf(sample)
+
+	serializersMutex.Lock()
+	defer serializersMutex.Unlock()
+
+	m := make(map[reflect.Type]func(interface{}) interface{})
+	if p := serializers.Load(); p != nil {
+		for t, f := range *p {
+			m[t] = f
+		}
+	}
+	if fn == nil {
+		delete(m, typ)
+	} else {
+		m[typ] = fn
+	}
+	serializers.Store(&m)
+}
+
+// resetSerializers removes all serializers and registers the default ones.
+func resetSerializers() {
+	serializersMutex.Lock()
+	serializers.Store(nil)
+	serializersMutex.Unlock()
+
+	registerDefaultSerializers()
+}
+
+// serialize returns the serialized value and true if a serializer is
+// registered for the type of the given value.
+func serialize(val interface{}) (interface{}, bool) {
+	p := serializers.Load()
+	if p == nil || len(*p) == 0 || val == nil {
+		return nil, false
+	}
+	fn, ok := (*p)[reflect.TypeOf(val)]
+	if !ok {
+		return nil, false
+	}
+	return fn(val), true
+}
@@ -0,0 +1,65 @@
+package log_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestRegisterSerializer(t *testing.T) {
+	log.RegisterSerializer(point{}, func(v interface{}) interface{} {
+		p := v.(point)
+		return fmt.Sprintf("(%d,%d)", p.X, p.Y)
+	})
+	defer log.RegisterSerializer(point{}, nil)
+
+	for _, test := range []struct {
+		handler string
+		want    string
+	}{
+		{handler: "text", want: "pos=(1,2)"},
+		{handler: "json", want: `"pos":"(1,2)"`},
+	} {
+		t.Run(test.handler, func(t *testing.T) {
+			f := filepath.Join(t.TempDir(), "test.log")
+			lg := log.New(&log.Config{
+				Level:   "info",
+				Handler: test.handler,
+				File:    &log.LumberjackConfig{Filename: f},
+			})
+			lg.Info("message", "pos", point{X: 1, Y: 2})
+			require.NoError(t, lg.Close())
+
+			bts, err := os.ReadFile(f)
+			require.NoError(t, err)
+			require.Contains(t, string(bts), test.want)
+		})
+	}
+
+	lg := log.New(&log.Config{Level: "info", Handler: "memory"})
+	mh := lg.Handler().(*memory.Handler)
+
+	// exact type match only
+	lg.Info("message", "pos", &point{X: 1, Y: 2})
+	require.Equal(t, &point{X: 1, Y: 2}, mh.Entries[0].Fields.Get("pos"))
+
+	// built-in
+	lg.Info("message", "ip", net.IPv4(10, 0, 0, 1))
+	require.Equal(t, "10.0.0.1", mh.Entries[1].Fields.Get("ip"))
+
+	// removed
+	log.RegisterSerializer(point{}, nil)
+	lg.Info("message", "pos", point{X: 1, Y: 2})
+	require.Equal(t, point{X: 1, Y: 2}, mh.Entries[2].Fields.Get("pos"))
+}
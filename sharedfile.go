@@ -0,0 +1,160 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/eluv-io/log-go/handlers/audit"
+)
+
+// sharedFiles is the registry of the shared log files by path, see
+// openSharedFile.
+var sharedFiles sync.Map
+
+// sharedFile is a log file shared by all logs writing to it. It serializes
+// their writes and coordinates rotation through a single lumberjack logger.
+// Closing a shared file is harmless: it is re-opened on the next write.
+type sharedFile struct {
+	mutex  sync.Mutex
+	path   string
+	ljack  *lumberjack.Logger
+	gzip   *gzipWriter   // compresses the output of logs with CompressStream
+	banner func() []byte // renders the banner, see Config.Banner - nil if disabled
+	size   int64         // the size of the current file if tracked, -1 otherwise
+	chain  *audit.Chain  // the hash chain of audit handlers - nil until needed
+}
+
+// openSharedFile returns the shared file for the file of the given config,
+// registering it if needed. If the rotation settings of the config differ from
+// the ones of the registered file, e.g. after a config change, the file
+// switches to the new settings.
+func openSharedFile(c *LumberjackConfig) *sharedFile {
+	path, err := filepath.Abs(c.path())
+	if err != nil {
+		path = filepath.Clean(c.path())
+	}
+	ljack := NewLumberjackLogger(c)
+	f := &sharedFile{path: path, ljack: ljack, size: -1}
+	f.gzip = newGzipWriter(f, f)
+	if prev, loaded := sharedFiles.LoadOrStore(path, f); loaded {
+		f = prev.(*sharedFile)
+		f.setRotation(ljack)
+		return f
+	}
+	metrics().FileCreated()
+	return f
+}
+
+// setRotation replaces the lumberjack logger of the file with the given one if
+// their rotation settings differ.
+func (f *sharedFile) setRotation(ljack *lumberjack.Logger) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if sameRotation(f.ljack, ljack) {
+		return
+	}
+	_ = f.ljack.Close()
+	f.ljack = ljack
+	f.size = -1
+}
+
+// setBanner sets the function rendering the banner written at the start of
+// each new file, see Config.Banner. A nil function disables the banner.
+func (f *sharedFile) setBanner(banner func() []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.banner = banner
+	f.size = -1
+}
+
+// auditChain returns the hash chain shared by all audit handlers writing to
+// the file. The chain continues the chain of an existing file - on error a new
+// chain is started.
+func (f *sharedFile) auditChain() *audit.Chain {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.chain == nil {
+		hash, _ := audit.LastHash(f.path)
+		f.chain = audit.NewChain(hash)
+	}
+	return f.chain
+}
+
+// compressed returns the writer compressing to the file. It is shared by all
+// logs with CompressStream writing to the file, which must not be mixed with
+// logs writing uncompressed output to the same file.
+func (f *sharedFile) compressed() *gzipWriter {
+	return f.gzip
+}
+
+// Write writes the given data to the file. If a banner is set, it is written
+// first if the file is new or empty or is rotated by the data.
+func (f *sharedFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.banner == nil {
+		return f.ljack.Write(p)
+	}
+	f.writeBanner(len(p))
+	n, err := f.ljack.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// writeBanner writes the banner if the file is new or empty or if writing n
+// bytes rotates it. In the latter case, the file is rotated before the banner
+// is written, like lumberjack would before writing the data.
+func (f *sharedFile) writeBanner(n int) {
+	if f.size < 0 {
+		f.size = 0
+		if info, err := os.Stat(f.path); err == nil {
+			f.size = info.Size()
+		}
+	}
+	if f.size > 0 {
+		if f.size+int64(n) <= maxFileSize(f.ljack) {
+			return
+		}
+		if err := f.ljack.Rotate(); err != nil {
+			return
+		}
+		f.size = 0
+	}
+	m, _ := f.ljack.Write(f.banner())
+	f.size += int64(m)
+}
+
+// Close closes the file.
+func (f *sharedFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.size = -1
+	return f.ljack.Close()
+}
+
+// maxFileSize returns the size in bytes at which the given lumberjack logger
+// rotates its file.
+func maxFileSize(ljack *lumberjack.Logger) int64 {
+	if ljack.MaxSize == 0 {
+		return 100 * 1024 * 1024
+	}
+	return int64(ljack.MaxSize) * 1024 * 1024
+}
+
+// sameRotation returns true if the given lumberjack loggers have the same
+// rotation settings.
+func sameRotation(a, b *lumberjack.Logger) bool {
+	return a.MaxSize == b.MaxSize &&
+		a.MaxAge == b.MaxAge &&
+		a.MaxBackups == b.MaxBackups &&
+		a.LocalTime == b.LocalTime &&
+		a.Compress == b.Compress
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// InstallSignalHandlers flushes all log files when one of the given signals is
+// received: the files are closed and re-opened with the next entry, which also
+// picks up files that were moved by external log rotation. Defaults to SIGHUP
+// if no signals are given.
+//
+// Note that the default behavior of the given signals is disabled, e.g. the
+// process is not terminated on SIGTERM anymore. Applications that register
+// SIGTERM are therefore expected to handle it themselves as well.
+//
+// Returns a function that uninstalls the signal handlers.
+func InstallSignalHandlers(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				getLogRoot().doLocked(func(r *logRoot) {
+					r.closeLogs()
+				})
+				def().Info("log files flushed", "signal", sig.String())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+)
+
+func TestInstallSignalHandlers(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "test.log")
+	log.SetDefault(&log.Config{
+		Level:   "info",
+		Handler: "text",
+		File:    &log.LumberjackConfig{Filename: f},
+	})
+	defer log.SetDefault(log.NewConfig())
+	defer log.CloseLogFiles()
+
+	stop := log.InstallSignalHandlers(syscall.SIGUSR1)
+	defer stop()
+
+	log.Info("before signal")
+	moved := filepath.Join(dir, "moved.log")
+	require.NoError(t, os.Rename(f, moved))
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	// the file is re-opened after the flush
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(f); err == nil {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "log file not re-opened")
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.CloseLogFiles()
+
+	bts, err := os.ReadFile(f)
+	require.NoError(t, err)
+	require.Contains(t, string(bts), "log files flushed")
+	require.Contains(t, string(bts), "signal=user defined signal 1")
+
+	bts, err = os.ReadFile(moved)
+	require.NoError(t, err)
+	require.Contains(t, string(bts), "before signal")
+	require.NotContains(t, string(bts), "log files flushed")
+}
@@ -0,0 +1,204 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eluv-io/utc-go"
+)
+
+// Health logs the result of a health check of the given component with
+// standardized fields 'component', 'healthy' and 'detail'. The entry is logged
+// at Info level if the component is healthy, at Warn level otherwise.
+func (l *Log) Health(component string, ok bool, detail string, fields ...interface{}) {
+	f := make([]interface{}, 0, len(fields)+6)
+	f = append(f, "component", component, "healthy", ok, "detail", detail)
+	f = append(f, fields...)
+	if ok {
+		l.get().Info("health check", f...)
+	} else {
+		l.get().Warn("health check", f...)
+	}
+}
+
+// Rate logs the throughput of a transfer or processing step with standardized
+// fields 'name', 'bytes', 'duration_ms' and 'bytes_per_sec' at Info level. The
+// 'bytes_per_sec' field is omitted if the duration is not positive.
+func (l *Log) Rate(name string, bytes int64, d time.Duration, fields ...interface{}) {
+	f := make([]interface{}, 0, len(fields)+8)
+	f = append(f, "name", name, "bytes", bytes, "duration_ms", d.Milliseconds())
+	if d > 0 {
+		f = append(f, "bytes_per_sec", int64(float64(bytes)/d.Seconds()))
+	}
+	f = append(f, fields...)
+	l.get().Info("rate", f...)
+}
+
+// Transition logs the state transition of the given entity with standardized
+// fields 'entity', 'from_state' and 'to_state' at Info level.
+func (l *Log) Transition(entity, from, to string, fields ...interface{}) {
+	f := make([]interface{}, 0, len(fields)+6)
+	f = append(f, "entity", entity, "from_state", from, "to_state", to)
+	f = append(f, fields...)
+	l.get().Info("state transition", f...)
+}
+
+// Cache logs a cache lookup with standardized fields 'cache' and 'cache_hit'
+// at Debug level. Hits and misses are counted regardless of the log level, see
+// CacheStats and CacheMetrics.
+func (l *Log) Cache(name string, hit bool, fields ...interface{}) {
+	lg := l.get()
+	countCache(lg.name, name, hit)
+	if !lg.IsDebug() {
+		return
+	}
+	f := make([]interface{}, 0, len(fields)+4)
+	f = append(f, "cache", name, "cache_hit", hit)
+	f = append(f, fields...)
+	lg.Debug("cache", f...)
+}
+
+// RequestIDHeader is the name of the header holding the request ID logged by
+// HTTPRequest.
+var RequestIDHeader = "X-Request-Id"
+
+// HTTPRequest returns a Log that adds standardized fields describing the given
+// HTTP request to all entries: 'method', 'path', 'client_ip', 'user_agent' and
+// 'request_id' (from the RequestIDHeader). The client IP is the first address
+// of the X-Forwarded-For header if present, the host of the request's remote
+// address otherwise. The request body is not logged - a dump of the request may
+// be added explicitly as 'raw' field for the raw handler:
+//
+//	reqLog := lg.HTTPRequest(r)
+//	reqLog.Info("request", "raw", dump)
+func (l *Log) HTTPRequest(r *http.Request) *Log {
+	return l.WithMap(map[string]interface{}{
+		"method":     r.Method,
+		"path":       r.URL.Path,
+		"client_ip":  clientIP(r),
+		"user_agent": r.UserAgent(),
+		"request_id": r.Header.Get(RequestIDHeader),
+	})
+}
+
+// requestIDGenerator holds the function generating request IDs.
+type requestIDGenerator struct {
+	fn func() string
+}
+
+// pRequestIDGenerator is a pointer to the request ID generator - nil for the
+// default generator
+var pRequestIDGenerator atomic.Pointer[requestIDGenerator]
+
+// SetRequestIDGenerator sets the function generating the request IDs of
+// WithNewRequestID, e.g. for deterministic IDs in tests. A nil function restores
+// the default generator, which creates 16 random hex characters.
+func SetRequestIDGenerator(fn func() string) {
+	if fn == nil {
+		pRequestIDGenerator.Store(nil)
+		return
+	}
+	pRequestIDGenerator.Store(&requestIDGenerator{fn: fn})
+}
+
+// WithNewRequestID generates a new request ID for services without upstream
+// request IDs. Returns a Log that adds the ID as 'request_id' field to all
+// entries, as well as the ID itself for propagating it downstream:
+//
+//	reqLog, id := lg.WithNewRequestID()
+//	req.Header.Set(log.RequestIDHeader, id)
+func (l *Log) WithNewRequestID() (*Log, string) {
+	id := newRequestID()
+	return l.WithMap(map[string]interface{}{"request_id": id}), id
+}
+
+// newRequestID returns a new request ID created by the configured generator.
+func newRequestID() string {
+	if g := pRequestIDGenerator.Load(); g != nil {
+		return g.fn()
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// should not happen - fall back to the time
+		return strconv.FormatInt(utc.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// clientIP returns the IP address of the client that sent the given request.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		ip, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(ip)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Elapsed starts timing an operation and returns a function that logs the
+// given message at Debug level with the elapsed time as 'elapsed' field:
+//
+//	defer lg.Elapsed("processing request", "request_id", id)()
+//
+// If Debug is disabled, the time is not even captured and the returned
+// function is a no-op.
+func (l *Log) Elapsed(msg string, fields ...interface{}) func() {
+	if !l.IsDebug() {
+		return func() {}
+	}
+	start := utc.Now()
+	return func() {
+		f := make([]interface{}, 0, len(fields)+2)
+		f = append(f, fields...)
+		f = append(f, "elapsed", utc.Now().Sub(start))
+		l.get().Debug(msg, f...)
+	}
+}
+
+// timerKey is the key of a timer started with StartTimer.
+type timerKey struct {
+	goID int64
+	name string
+}
+
+// timers holds the start times of the timers started with StartTimer.
+var timers sync.Map
+
+// StartTimer starts the timer with the given name for the current goroutine.
+// The timer is stopped with StopTimer, which logs the elapsed time. Starting
+// a running timer restarts it. This avoids passing start times between the
+// functions logging the start and the end of an operation:
+//
+//	lg.StartTimer("import")
+//	...
+//	lg.StopTimer("import")
+//
+// Timers are not bound to the Log: a timer started with one Log may be stopped
+// with another. Timers that are never stopped are retained.
+func (l *Log) StartTimer(name string) {
+	timers.Store(timerKey{goID: goID(), name: name}, utc.Now())
+}
+
+// StopTimer stops the timer with the given name for the current goroutine and
+// logs the name as message at Debug level with the elapsed time as 'elapsed'
+// field. Returns the elapsed time, or 0 if the timer was not started in the
+// current goroutine, in which case nothing is logged.
+func (l *Log) StopTimer(name string) time.Duration {
+	start, ok := timers.LoadAndDelete(timerKey{goID: goID(), name: name})
+	if !ok {
+		return 0
+	}
+	d := utc.Now().Sub(start.(utc.UTC))
+	l.get().Debug(name, "elapsed", d)
+	return d
+}
@@ -0,0 +1,221 @@
+package log_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+	"github.com/eluv-io/log-go"
+	"github.com/eluv-io/utc-go"
+)
+
+func newMemoryLog(level string) (*log.Log, *memory.Handler) {
+	lg := log.New(&log.Config{
+		Level:   level,
+		Handler: "memory",
+	})
+	return lg, lg.Handler().(*memory.Handler)
+}
+
+func TestHealth(t *testing.T) {
+	lg, handler := newMemoryLog("info")
+
+	lg.Health("db", true, "connected", "latency_ms", 3)
+	lg.Health("cache", false, "connection refused")
+
+	require.Equal(t, 2, len(handler.Entries))
+
+	e := handler.Entries[0]
+	require.Equal(t, "info", e.Level.String())
+	require.Equal(t, "health check", e.Message)
+	require.Equal(t, "db", e.Fields.Get("component"))
+	require.Equal(t, true, e.Fields.Get("healthy"))
+	require.Equal(t, "connected", e.Fields.Get("detail"))
+	require.Equal(t, 3, e.Fields.Get("latency_ms"))
+
+	e = handler.Entries[1]
+	require.Equal(t, "warn", e.Level.String())
+	require.Equal(t, "cache", e.Fields.Get("component"))
+	require.Equal(t, false, e.Fields.Get("healthy"))
+	require.Equal(t, "connection refused", e.Fields.Get("detail"))
+}
+
+func TestRate(t *testing.T) {
+	lg, handler := newMemoryLog("info")
+
+	lg.Rate("upload", 3_000_000, 1500*time.Millisecond, "file", "a.mp4")
+	lg.Rate("upload", 1024, 0)
+
+	require.Equal(t, 2, len(handler.Entries))
+
+	e := handler.Entries[0]
+	require.Equal(t, "info", e.Level.String())
+	require.Equal(t, "rate", e.Message)
+	require.Equal(t, "upload", e.Fields.Get("name"))
+	require.Equal(t, int64(3_000_000), e.Fields.Get("bytes"))
+	require.Equal(t, int64(1500), e.Fields.Get("duration_ms"))
+	require.Equal(t, int64(2_000_000), e.Fields.Get("bytes_per_sec"))
+	require.Equal(t, "a.mp4", e.Fields.Get("file"))
+
+	e = handler.Entries[1]
+	require.Equal(t, int64(1024), e.Fields.Get("bytes"))
+	require.Equal(t, int64(0), e.Fields.Get("duration_ms"))
+	require.Nil(t, e.Fields.Get("bytes_per_sec"))
+}
+
+func TestTransition(t *testing.T) {
+	lg, handler := newMemoryLog("info")
+
+	lg.Transition("job", "queued", "running", "job_id", "j-1")
+
+	require.Equal(t, 1, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "info", e.Level.String())
+	require.Equal(t, "state transition", e.Message)
+	require.Equal(t, "job", e.Fields.Get("entity"))
+	require.Equal(t, "queued", e.Fields.Get("from_state"))
+	require.Equal(t, "running", e.Fields.Get("to_state"))
+	require.Equal(t, "j-1", e.Fields.Get("job_id"))
+}
+
+func TestCache(t *testing.T) {
+	lg, handler := newMemoryLog("debug")
+
+	lg.Cache("test-cache-a", true, "key", "k1")
+	lg.Cache("test-cache-a", false, "key", "k2")
+	lg.Cache("test-cache-a", true)
+	lg.Cache("test-cache-b", false)
+
+	require.Equal(t, 4, len(handler.Entries))
+	entry := handler.Entries[1]
+	require.Equal(t, "debug", entry.Level.String())
+	require.Equal(t, "cache", entry.Message)
+	require.Equal(t, "test-cache-a", entry.Fields.Get("cache"))
+	require.Equal(t, false, entry.Fields.Get("cache_hit"))
+	require.Equal(t, "k2", entry.Fields.Get("key"))
+
+	// counted even if not logged
+	lg.SetLevel("info")
+	lg.Cache("test-cache-a", false)
+	require.Equal(t, 4, len(handler.Entries))
+
+	hits, misses := log.CacheStats("test-cache-a")
+	require.Equal(t, int64(2), hits)
+	require.Equal(t, int64(2), misses)
+	hits, misses = log.CacheStats("test-cache-b")
+	require.Equal(t, int64(0), hits)
+	require.Equal(t, int64(1), misses)
+	hits, misses = log.CacheStats("test-cache-unknown")
+	require.Equal(t, int64(0), hits+misses)
+}
+
+func TestHTTPRequest(t *testing.T) {
+	lg, handler := newMemoryLog("info")
+
+	r := httptest.NewRequest("GET", "http://example.com/q/content?x=1", nil)
+	r.RemoteAddr = "10.0.0.1:4711"
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("X-Request-Id", "req-123")
+	lg.HTTPRequest(r).Info("request", "status", 200)
+
+	r.Header.Set("X-Forwarded-For", "192.168.1.1, 10.0.0.2")
+	lg.HTTPRequest(r).Info("forwarded")
+
+	require.Equal(t, 2, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, []string{"client_ip", "method", "path", "request_id", "user_agent", "status"}, fieldNames(e.Fields))
+	require.Equal(t, "GET", e.Fields.Get("method"))
+	require.Equal(t, "/q/content", e.Fields.Get("path"))
+	require.Equal(t, "10.0.0.1", e.Fields.Get("client_ip"))
+	require.Equal(t, "test-agent", e.Fields.Get("user_agent"))
+	require.Equal(t, "req-123", e.Fields.Get("request_id"))
+	require.Equal(t, 200, e.Fields.Get("status"))
+	require.Equal(t, "192.168.1.1", handler.Entries[1].Fields.Get("client_ip"))
+}
+
+func TestWithNewRequestID(t *testing.T) {
+	count := 0
+	log.SetRequestIDGenerator(func() string {
+		count++
+		return fmt.Sprintf("id-%d", count)
+	})
+	defer log.SetRequestIDGenerator(nil)
+
+	lg, handler := newMemoryLog("info")
+	child, id := lg.WithNewRequestID()
+	require.Equal(t, "id-1", id)
+	child.Info("first")
+	child.Info("second", "key", "value")
+	lg.Info("parent")
+
+	require.Equal(t, 3, len(handler.Entries))
+	require.Equal(t, id, handler.Entries[0].Fields.Get("request_id"))
+	require.Equal(t, id, handler.Entries[1].Fields.Get("request_id"))
+	require.Nil(t, handler.Entries[2].Fields.Get("request_id"))
+
+	_, id2 := lg.WithNewRequestID()
+	require.Equal(t, "id-2", id2)
+
+	// the default generator creates random IDs
+	log.SetRequestIDGenerator(nil)
+	_, id3 := lg.WithNewRequestID()
+	_, id4 := lg.WithNewRequestID()
+	require.Len(t, id3, 16)
+	require.NotEqual(t, id3, id4)
+}
+
+func TestElapsed(t *testing.T) {
+	now := utc.UnixMilli(0)
+	defer utc.MockNow(now)()
+
+	lg, handler := newMemoryLog("debug")
+	done := lg.Elapsed("processing", "request_id", "r-1")
+	defer utc.MockNow(now.Add(1500 * time.Millisecond))()
+	done()
+
+	require.Equal(t, 1, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "debug", e.Level.String())
+	require.Equal(t, "processing", e.Message)
+	require.Equal(t, "r-1", e.Fields.Get("request_id"))
+	require.Equal(t, 1500*time.Millisecond, e.Fields.Get("elapsed"))
+
+	// not logged if debug is disabled
+	lg, handler = newMemoryLog("info")
+	lg.Elapsed("processing")()
+	require.Empty(t, handler.Entries)
+}
+
+func TestTimer(t *testing.T) {
+	now := utc.UnixMilli(0)
+	defer utc.MockNowFn(func() utc.UTC { return now })()
+
+	lg, handler := newMemoryLog("debug")
+	lg.StartTimer("import")
+	lg.StartTimer("other")
+	now = now.Add(2 * time.Second)
+	require.Equal(t, 2*time.Second, lg.StopTimer("import"))
+
+	// not started or already stopped
+	require.Equal(t, time.Duration(0), lg.StopTimer("import"))
+	require.Equal(t, time.Duration(0), lg.StopTimer("unknown"))
+
+	// timers are goroutine-local
+	done := make(chan time.Duration)
+	go func() { done <- lg.StopTimer("other") }()
+	require.Equal(t, time.Duration(0), <-done)
+
+	now = now.Add(time.Second)
+	require.Equal(t, 3*time.Second, lg.StopTimer("other"))
+
+	require.Equal(t, 2, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "debug", e.Level.String())
+	require.Equal(t, "import", e.Message)
+	require.Equal(t, 2*time.Second, e.Fields.Get("elapsed"))
+	require.Equal(t, 3*time.Second, handler.Entries[1].Fields.Get("elapsed"))
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"github.com/eluv-io/apexlog-go/handlers/memory"
+)
+
+// WithTag returns a Log that tags all entries with the given key and value.
+// Textual handlers (text, console, raw) also prefix messages with the value in
+// brackets, like Config.Prefix. The tag is removed once the returned expire
+// function is called: the returned Log then logs like this Log. See
+// logtest.WithTestName for the main use case.
+func (l *Log) WithTag(key, value string) (ret *Log, expire func()) {
+	ret = l.WithMap(map[string]interface{}{key: value})
+	ret.derived.prefix = "[" + value + "]"
+	return ret, func() {
+		ret.derived.expired.Store(true)
+	}
+}
+
+// SetDefaultMemory sets the default configuration, using a single memory
+// handler that records all entries of the default log and its named logs,
+// including those with their own handler settings, e.g. DeferUntil. Returns
+// the memory handler and a function that restores the previous default
+// configuration.
+//
+// The handler and file settings of the given config (including those of named
+// configs) are ignored. A nil config is equivalent to NewConfig(). See
+// logtest.SetDefaultForTest for the main use case.
+func SetDefaultMemory(c *Config) (handler *memory.Handler, restore func()) {
+	if c == nil {
+		c = NewConfig()
+	}
+	handler = memory.New()
+	conf := *c
+	conf.Handler = "memory"
+	conf.handler = handler
+	conf.File = nil
+	if len(c.Named) > 0 {
+		conf.Named = make(map[string]*Config, len(c.Named))
+		for path, named := range c.Named {
+			nc := *named
+			nc.Handler = ""
+			nc.File = nil
+			conf.Named[path] = &nc
+		}
+	}
+
+	var prev *Config
+	getLogRoot().doLocked(func(r *logRoot) {
+		prev = r.defConfig
+		r.applyConfigNoLock(&conf)
+	})
+	return handler, func() {
+		getLogRoot().doLocked(func(r *logRoot) {
+			r.applyConfigNoLock(prev)
+		})
+	}
+}
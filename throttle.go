@@ -0,0 +1,162 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eluv-io/utc-go"
+)
+
+// Throttled is a logger that limits the number of entries it emits.
+type Throttled interface {
+	Trace(msg string, fields ...interface{})
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// Burst returns a throttled logger that emits the first firstN entries
+// unconditionally and then at most one entry per period. The number of entries
+// suppressed within a period is added as 'suppressed' field to the next
+// emitted entry. Useful for errors during startup, where the first occurrences
+// are of interest in full:
+//
+//	lg.Burst("connect", 3, time.Minute).Warn("failed to connect", err)
+//
+// The throttling state is kept per key in the Log this Log is derived from,
+// e.g. with WithMap or HTTPRequest: subsequent calls with the same key on this
+// Log or any Log derived from the same Log share the state, regardless of the
+// given parameters. The returned logger logs through this Log and therefore
+// carries its base fields.
+//
+// An optional clock may be provided as time source for the periods, e.g. for
+// testing. Default: utc.Now
+func (l *Log) Burst(key string, firstN int, period time.Duration, clock ...func() utc.UTC) Throttled {
+	base := l
+	if l.derived != nil {
+		base = l.derived.parent
+	}
+	t, ok := base.bursts.Load(key)
+	if !ok {
+		now := utc.Now
+		if len(clock) > 0 && clock[0] != nil {
+			now = clock[0]
+		}
+		t, _ = base.bursts.LoadOrStore(key, &burstLog{
+			log: base,
+			state: &burstState{
+				firstN: firstN,
+				period: period,
+				now:    now,
+			},
+		})
+	}
+	b := t.(*burstLog)
+	if l == base {
+		return b
+	}
+	return &burstLog{log: l, state: b.state}
+}
+
+// burstLog implements a "first N then throttle" Throttled logger.
+type burstLog struct {
+	log   *Log
+	state *burstState
+}
+
+// burstState is the throttling state of the burst loggers of a key.
+type burstState struct {
+	firstN int
+	period time.Duration
+	now    func() utc.UTC
+
+	mutex      sync.Mutex
+	count      int     // number of entries emitted unconditionally
+	emitted    utc.UTC // time the last entry was emitted
+	suppressed int     // number of entries suppressed since then
+}
+
+// limit returns the fields to log and true if the entry is to be emitted.
+func (b *burstState) limit(fields []interface{}) ([]interface{}, bool) {
+	now := b.now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.count < b.firstN {
+		b.count++
+		b.emitted = now
+		return fields, true
+	}
+	if now.Sub(b.emitted) < b.period {
+		b.suppressed++
+		return fields, false
+	}
+
+	suppressed := b.suppressed
+	b.emitted = now
+	b.suppressed = 0
+	if suppressed == 0 {
+		return fields, true
+	}
+	ret := make([]interface{}, 0, len(fields)+2)
+	ret = append(ret, fields...)
+	ret = append(ret, "suppressed", suppressed)
+	return ret, true
+}
+
+// Trace logs the given message at the Trace level unless throttled.
+func (b *burstLog) Trace(msg string, fields ...interface{}) {
+	lg := b.log.get()
+	if !lg.IsTrace() {
+		return
+	}
+	if fields, ok := b.state.limit(fields); ok {
+		lg.Trace(msg, fields...)
+	}
+}
+
+// Debug logs the given message at the Debug level unless throttled.
+func (b *burstLog) Debug(msg string, fields ...interface{}) {
+	lg := b.log.get()
+	if !lg.IsDebug() {
+		return
+	}
+	if fields, ok := b.state.limit(fields); ok {
+		lg.Debug(msg, fields...)
+	}
+}
+
+// Info logs the given message at the Info level unless throttled.
+func (b *burstLog) Info(msg string, fields ...interface{}) {
+	lg := b.log.get()
+	if !lg.IsInfo() {
+		return
+	}
+	if fields, ok := b.state.limit(fields); ok {
+		lg.Info(msg, fields...)
+	}
+}
+
+// Warn logs the given message at the Warn level unless throttled.
+func (b *burstLog) Warn(msg string, fields ...interface{}) {
+	lg := b.log.get()
+	if !lg.IsWarn() {
+		return
+	}
+	if fields, ok := b.state.limit(fields); ok {
+		lg.Warn(msg, fields...)
+	}
+}
+
+// Error logs the given message at the Error level unless throttled.
+func (b *burstLog) Error(msg string, fields ...interface{}) {
+	lg := b.log.get()
+	if !lg.IsError() {
+		return
+	}
+	if fields, ok := b.state.limit(fields); ok {
+		lg.Error(msg, fields...)
+	}
+}
@@ -0,0 +1,86 @@
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/utc-go"
+)
+
+func TestBurst(t *testing.T) {
+	now := utc.UnixMilli(0)
+	defer utc.MockNow(now)()
+
+	lg, handler := newMemoryLog("info")
+	burst := lg.Burst("connect", 3, time.Minute)
+	require.Same(t, burst, lg.Burst("connect", 5, time.Hour))
+
+	for i := 0; i < 20; i++ {
+		burst.Warn("failed to connect", "attempt", i)
+	}
+	burst.Debug("not logged")
+
+	require.Equal(t, 3, len(handler.Entries))
+	for i, e := range handler.Entries {
+		require.Equal(t, "failed to connect", e.Message)
+		require.Equal(t, i, e.Fields.Get("attempt"))
+		require.Nil(t, e.Fields.Get("suppressed"))
+	}
+
+	defer utc.MockNow(now.Add(time.Minute))()
+	burst.Warn("failed to connect", "attempt", 20)
+	burst.Warn("failed to connect", "attempt", 21)
+
+	require.Equal(t, 4, len(handler.Entries))
+	require.Equal(t, 20, handler.Entries[3].Fields.Get("attempt"))
+	require.Equal(t, 17, handler.Entries[3].Fields.Get("suppressed"))
+}
+
+func TestBurstClock(t *testing.T) {
+	now := utc.UnixMilli(0)
+	clock := func() utc.UTC { return now }
+
+	lg, handler := newMemoryLog("info")
+	burst := lg.Burst("connect", 1, time.Minute, clock)
+
+	burst.Warn("failed to connect", "attempt", 0)
+	now = now.Add(59 * time.Second)
+	burst.Warn("failed to connect", "attempt", 1)
+	require.Equal(t, 1, len(handler.Entries))
+
+	now = now.Add(time.Second)
+	burst.Warn("failed to connect", "attempt", 2)
+	require.Equal(t, 2, len(handler.Entries))
+	require.Equal(t, 1, handler.Entries[1].Fields.Get("suppressed"))
+}
+
+func TestBurstWithBaseFields(t *testing.T) {
+	lg, handler := newMemoryLog("info")
+	wm := lg.WithMap(map[string]interface{}{"request_id": "r1"})
+	burst := wm.Burst("connect", 1, time.Hour)
+
+	burst.Warn("failed to connect", "attempt", 0)
+	burst.Warn("failed to connect", "attempt", 1)
+
+	require.Equal(t, 1, len(handler.Entries))
+	e := handler.Entries[0]
+	require.Equal(t, "r1", e.Fields.Get("request_id"))
+	require.Equal(t, 0, e.Fields.Get("attempt"))
+
+	// the throttling state is shared with the base Log and all derived logs
+	lg.Burst("connect", 1, time.Hour).Warn("failed to connect", "attempt", 2)
+	lg.WithMap(map[string]interface{}{"request_id": "r2"}).Burst("connect", 1, time.Hour).Warn("failed to connect", "attempt", 3)
+	wm.Burst("connect", 1, time.Hour).Warn("failed to connect", "attempt", 4)
+	require.Equal(t, 1, len(handler.Entries))
+
+	// but logs through the Log it was obtained from
+	burst = lg.Burst("other", 2, time.Hour)
+	burst.Warn("first")
+	wm.Burst("other", 2, time.Hour).Warn("second")
+	burst.Warn("third")
+	require.Equal(t, 3, len(handler.Entries))
+	require.Nil(t, handler.Entries[1].Fields.Get("request_id"))
+	require.Equal(t, "r1", handler.Entries[2].Fields.Get("request_id"))
+}
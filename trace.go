@@ -0,0 +1,45 @@
+package log
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+var (
+	// pTraceIDExtractor is a pointer to the function extracting trace ids from
+	// contexts - nil if not set
+	pTraceIDExtractor atomic.Pointer[func(ctx context.Context) (string, bool)]
+)
+
+// SetTraceIDExtractor sets the function used to extract the trace id from a
+// context, e.g. for OpenTelemetry:
+//
+//	log.SetTraceIDExtractor(func(ctx context.Context) (string, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return sc.TraceID().String(), sc.HasTraceID()
+//	})
+//
+// This keeps the log package independent of any particular tracing library.
+// A nil function removes the extractor.
+func SetTraceIDExtractor(fn func(ctx context.Context) (string, bool)) {
+	if fn == nil {
+		pTraceIDExtractor.Store(nil)
+		return
+	}
+	pTraceIDExtractor.Store(&fn)
+}
+
+// TraceID returns the trace id of the given context, e.g. for attaching it as
+// exemplar to metrics. Returns false if no trace id extractor is set or the
+// context does not carry a trace id.
+func (l *Log) TraceID(ctx context.Context) (string, bool) {
+	fn := pTraceIDExtractor.Load()
+	if fn == nil || ctx == nil {
+		return "", false
+	}
+	id, ok := (*fn)(ctx)
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, true
+}
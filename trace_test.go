@@ -0,0 +1,33 @@
+package log_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/log-go"
+)
+
+type traceIDKey struct{}
+
+func TestTraceID(t *testing.T) {
+	lg := log.Get("/trace")
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	_, ok := lg.TraceID(ctx)
+	require.False(t, ok)
+
+	log.SetTraceIDExtractor(func(ctx context.Context) (string, bool) {
+		id, ok := ctx.Value(traceIDKey{}).(string)
+		return id, ok
+	})
+	defer log.SetTraceIDExtractor(nil)
+
+	id, ok := lg.TraceID(ctx)
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", id)
+
+	_, ok = lg.TraceID(context.Background())
+	require.False(t, ok)
+}